@@ -277,7 +277,7 @@ func (di *Dependencies) bootstrapHermesPromiseSettler(nodeOptions node.Options)
 
 // bootstrapServiceComponents initiates ServicesManager dependency
 func (di *Dependencies) bootstrapServiceComponents(nodeOptions node.Options) error {
-	di.NATService = nat.NewService()
+	di.NATService = nat.NewService(di.EventBus)
 	if err := di.NATService.Enable(); err != nil {
 		log.Warn().Err(err).Msg("Failed to enable NAT forwarding")
 	}
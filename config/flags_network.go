@@ -126,6 +126,12 @@ var (
 		Usage: "Range of UDP listen ports used for connections",
 		Value: "10000:60000",
 	}
+	// FlagUDPPortsLinger sets how long released UDP ports are kept out of use.
+	FlagUDPPortsLinger = cli.DurationFlag{
+		Name:  "udp.ports.linger",
+		Usage: `Period after which released UDP ports can be used again, 0 disables it { "30s", "3m" }`,
+		Value: 0,
+	}
 	// FlagTraversal order of NAT traversal methods to be used for providing service.
 	FlagTraversal = cli.StringFlag{
 		Name:  "traversal",
@@ -175,6 +181,7 @@ func RegisterFlagsNetwork(flags *[]cli.Flag) {
 		&FlagSTUNservers,
 		&FlagLocalServiceDiscovery,
 		&FlagUDPListenPorts,
+		&FlagUDPPortsLinger,
 		&FlagTraversal,
 		&FlagPortCheckServers,
 		&FlagStatsReportInterval,
@@ -199,6 +206,7 @@ func ParseFlagsNetwork(ctx *cli.Context) {
 	Current.ParseStringSliceFlag(ctx, FlagSTUNservers)
 	Current.ParseBoolFlag(ctx, FlagLocalServiceDiscovery)
 	Current.ParseStringFlag(ctx, FlagUDPListenPorts)
+	Current.ParseDurationFlag(ctx, FlagUDPPortsLinger)
 	Current.ParseStringFlag(ctx, FlagTraversal)
 	Current.ParseStringFlag(ctx, FlagPortCheckServers)
 	Current.ParseDurationFlag(ctx, FlagStatsReportInterval)
@@ -96,9 +96,27 @@ var (
 	// FlagFirewallProtectedNetworks protects provider's networks from access via VPN
 	FlagFirewallProtectedNetworks = cli.StringFlag{
 		Name:  "firewall.protected.networks",
-		Usage: "List of comma separated (no spaces) subnets to be protected from access via VPN",
+		Usage: "List of comma separated (no spaces) subnets to be protected from access via VPN, 'private' expands to the standard private IPv4 subnets. Only IPv4 subnets are protected, IPv6 ones are ignored with a warning",
 		Value: "10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,127.0.0.0/8",
 	}
+	// FlagFirewallReconcileInterval sets how often NAT/firewall rules are checked and restored if missing.
+	FlagFirewallReconcileInterval = cli.DurationFlag{
+		Name:  "firewall.reconcile.interval",
+		Usage: `Interval of restoring NAT/firewall rules removed by other tools, 0 disables it { "30s", "3m", "1h20m30s" }`,
+		Value: 0,
+	}
+	// FlagFirewallVerifyTeardown enables checking that NAT/firewall rules are gone after removal.
+	FlagFirewallVerifyTeardown = cli.BoolFlag{
+		Name:  "firewall.verify-teardown",
+		Usage: "Check that NAT/firewall rules are really removed when NAT service is disabled",
+		Value: false,
+	}
+	// FlagFirewallMaxRules limits how many NAT/firewall rules the node may set up.
+	FlagFirewallMaxRules = cli.IntFlag{
+		Name:  "firewall.max-rules",
+		Usage: "Maximum number of NAT/firewall rules set up by the node, not counting protected network rules. 0 means unlimited",
+		Value: 0,
+	}
 	// FlagShaperEnabled enables bandwidth limitation.
 	FlagShaperEnabled = cli.BoolFlag{
 		Name:  "shaper.enabled",
@@ -324,6 +342,9 @@ func RegisterFlagsNode(flags *[]cli.Flag) error {
 		&FlagFeedbackURL,
 		&FlagFirewallKillSwitch,
 		&FlagFirewallProtectedNetworks,
+		&FlagFirewallReconcileInterval,
+		&FlagFirewallVerifyTeardown,
+		&FlagFirewallMaxRules,
 		&FlagShaperEnabled,
 		&FlagShaperBandwidth,
 		&FlagKeystoreLightweight,
@@ -385,6 +406,9 @@ func ParseFlagsNode(ctx *cli.Context) {
 	Current.ParseStringFlag(ctx, FlagFeedbackURL)
 	Current.ParseBoolFlag(ctx, FlagFirewallKillSwitch)
 	Current.ParseStringFlag(ctx, FlagFirewallProtectedNetworks)
+	Current.ParseDurationFlag(ctx, FlagFirewallReconcileInterval)
+	Current.ParseBoolFlag(ctx, FlagFirewallVerifyTeardown)
+	Current.ParseIntFlag(ctx, FlagFirewallMaxRules)
 	Current.ParseBoolFlag(ctx, FlagShaperEnabled)
 	Current.ParseUInt64Flag(ctx, FlagShaperBandwidth)
 	Current.ParseBoolFlag(ctx, FlagKeystoreLightweight)
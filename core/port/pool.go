@@ -20,6 +20,8 @@ package port
 import (
 	"fmt"
 	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -31,8 +33,18 @@ import (
 type Pool struct {
 	start, capacity int
 	rand            *rand.Rand
+
+	linger      time.Duration
+	reservation time.Duration
+	mu          sync.Mutex
+	lingering   map[int]time.Time
+	reserved    map[int]time.Time
 }
 
+// DefaultReservation is how long a port handed out by a reserving pool is kept
+// away from other callers while its owner gets around to binding it.
+const DefaultReservation = 10 * time.Second
+
 // ServicePortSupplier provides port needed to run a service on
 type ServicePortSupplier interface {
 	Acquire() (Port, error)
@@ -48,6 +60,68 @@ func NewFixedRangePool(r Range) *Pool {
 	}
 }
 
+// NewFixedRangePoolWithLinger creates a fixed size pool from port.Range which
+// does not hand out released ports again until the linger period passes.
+// Acquired ports are also reserved for DefaultReservation, as with
+// NewFixedRangePoolWithReservation, so they are not handed out twice before
+// they are bound or released.
+func NewFixedRangePoolWithLinger(r Range, linger time.Duration) *Pool {
+	pool := NewFixedRangePoolWithReservation(r, DefaultReservation)
+	pool.linger = linger
+	pool.lingering = make(map[int]time.Time)
+	return pool
+}
+
+// NewFixedRangePoolWithReservation creates a fixed size pool from port.Range
+// which marks acquired ports reserved until they are released or the
+// reservation expires. This keeps concurrent callers from being handed the same
+// port in the window between acquiring and actually binding it.
+func NewFixedRangePoolWithReservation(r Range, reservation time.Duration) *Pool {
+	pool := NewFixedRangePool(r)
+	pool.reservation = reservation
+	pool.reserved = make(map[int]time.Time)
+	return pool
+}
+
+// Release returns ports back to the pool. If pool has a linger period,
+// released ports are not handed out until it passes, so a NAT mapping
+// which is still warm for the previous peer is not reused by another session.
+// Released ports also drop their reservation.
+func (pool *Pool) Release(ports ...Port) {
+	if pool.linger <= 0 && pool.reservation <= 0 {
+		return
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	until := time.Now().Add(pool.linger)
+	for _, p := range ports {
+		delete(pool.reserved, p.Num())
+		if pool.linger > 0 {
+			pool.lingering[p.Num()] = until
+		}
+	}
+}
+
+// held reports whether the port is lingering or reserved, dropping expired
+// entries on the way. Must be called with pool.mu held.
+func (pool *Pool) held(p int, now time.Time) bool {
+	return pool.holds(pool.lingering, p, now) || pool.holds(pool.reserved, p, now)
+}
+
+func (pool *Pool) holds(set map[int]time.Time, p int, now time.Time) bool {
+	until, ok := set[p]
+	if !ok {
+		return false
+	}
+	if now.After(until) {
+		delete(set, p)
+		return false
+	}
+	return true
+}
+
 // Acquire returns an unused port in pool's range
 func (pool *Pool) Acquire() (Port, error) {
 	p, err := pool.seekAvailablePort()
@@ -56,17 +130,51 @@ func (pool *Pool) Acquire() (Port, error) {
 }
 
 func (pool *Pool) seekAvailablePort() (int, error) {
+	pool.mu.Lock()
 	randomOffset := pool.rand.Intn(pool.capacity)
+	pool.mu.Unlock()
+
 	for i := 0; i < pool.capacity; i++ {
 		p := pool.start + (randomOffset+i)%pool.capacity
+		if !pool.claim(p) {
+			continue
+		}
+		// Probe without holding the lock, the claim keeps other callers away.
 		available, err := available(p)
-		if available || err != nil {
+		if available && err == nil {
+			return p, nil
+		}
+		pool.unclaim(p)
+		if err != nil {
 			return p, err
 		}
 	}
 	return 0, errors.New("port pool is exhausted")
 }
 
+// claim reserves the port unless it is lingering or reserved already.
+func (pool *Pool) claim(p int) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := time.Now()
+	if pool.held(p, now) {
+		return false
+	}
+	if pool.reservation > 0 {
+		pool.reserved[p] = now.Add(pool.reservation)
+	}
+	return true
+}
+
+// unclaim drops the reservation of a port which turned out to be unusable.
+func (pool *Pool) unclaim(p int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	delete(pool.reserved, p)
+}
+
 // AcquireMultiple returns n unused ports from pool's range.
 func (pool *Pool) AcquireMultiple(n int) (ports []Port, err error) {
 	if n > pool.capacity {
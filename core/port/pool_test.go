@@ -22,8 +22,10 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const testIterations = 10_000
@@ -71,6 +73,104 @@ func TestConcurrentUsage(t *testing.T) {
 	wg.Wait()
 }
 
+func TestReleasedPortLingers(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	require.NoError(t, err)
+	free := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	pool := NewFixedRangePoolWithLinger(Range{free, free + 1}, 50*time.Millisecond)
+	port, err := pool.Acquire()
+	require.NoError(t, err)
+	assert.Equal(t, free, port.Num())
+
+	pool.Release(port)
+	_, err = pool.Acquire()
+	assert.Error(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	port, err = pool.Acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, free, port.Num())
+}
+
+func TestReleaseWithoutLinger(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	require.NoError(t, err)
+	free := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	pool := NewFixedRangePool(Range{free, free + 1})
+	port, err := pool.Acquire()
+	require.NoError(t, err)
+
+	pool.Release(port)
+	port, err = pool.Acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, free, port.Num())
+}
+
+func TestConcurrentAcquireDoesNotOverlap(t *testing.T) {
+	pool := NewFixedRangePoolWithReservation(Range{59900, 60000}, time.Minute)
+
+	var wg sync.WaitGroup
+	results := make([][]Port, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ports, err := pool.AcquireMultiple(40)
+			assert.NoError(t, err)
+			results[i] = ports
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[Port]bool)
+	for _, ports := range results {
+		for _, p := range ports {
+			assert.False(t, seen[p], "port %d handed out twice", p)
+			seen[p] = true
+		}
+	}
+}
+
+func TestReservationExpires(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	require.NoError(t, err)
+	free := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	pool := NewFixedRangePoolWithReservation(Range{free, free + 1}, 50*time.Millisecond)
+	port, err := pool.Acquire()
+	require.NoError(t, err)
+	assert.Equal(t, free, port.Num())
+
+	_, err = pool.Acquire()
+	assert.Error(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+	port, err = pool.Acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, free, port.Num())
+}
+
+func TestReleaseDropsReservation(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	require.NoError(t, err)
+	free := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	pool := NewFixedRangePoolWithReservation(Range{free, free + 1}, time.Minute)
+	port, err := pool.Acquire()
+	require.NoError(t, err)
+
+	pool.Release(port)
+	port, err = pool.Acquire()
+	assert.NoError(t, err)
+	assert.Equal(t, free, port.Num())
+}
+
 func listenUDP(port int) error {
 	udpAddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(port))
 	if err != nil {
@@ -48,6 +48,43 @@ func (r Rule) RuleSpec(spec ...string) Rule {
 	return r
 }
 
+// WithComment tags the rule with a comment, so it can be found in iptables output.
+// Comment must not contain whitespace.
+func (r Rule) WithComment(comment string) Rule {
+	r.ruleSpec = append(append([]string{}, r.ruleSpec...), "-m", "comment", "--comment", comment)
+	return r
+}
+
+// Comment returns the comment the rule is tagged with.
+func (r Rule) Comment() string {
+	return r.specValue("--comment")
+}
+
+// Chain returns the chain name of the rule.
+func (r Rule) Chain() string {
+	return r.chainName
+}
+
+// Table returns the table of the rule.
+func (r Rule) Table() string {
+	if table := r.specValue("--table"); table != "" {
+		return table
+	}
+	if table := r.specValue("-t"); table != "" {
+		return table
+	}
+	return "filter"
+}
+
+func (r Rule) specValue(option string) string {
+	for i := 0; i < len(r.ruleSpec)-1; i++ {
+		if r.ruleSpec[i] == option {
+			return r.ruleSpec[i+1]
+		}
+	}
+	return ""
+}
+
 // ApplyArgs returns an argument list to be passed to the iptables executable to APPLY the rule.
 func (r Rule) ApplyArgs() []string {
 	return append(r.action, r.ruleSpec...)
@@ -58,6 +95,11 @@ func (r Rule) RemoveArgs() []string {
 	return append([]string{"-D", r.chainName}, r.ruleSpec...)
 }
 
+// CheckArgs returns an argument list to be passed to the iptables executable to CHECK if the rule exists.
+func (r Rule) CheckArgs() []string {
+	return append([]string{"-C", r.chainName}, r.ruleSpec...)
+}
+
 // Equals checks if two Rules are equal.
 func (r Rule) Equals(another Rule) bool {
 	return r.chainName == another.chainName &&
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRuleNotFound is returned when there is no rule with the given comment.
+var ErrRuleNotFound = errors.New("rule not found")
+
+// RuleCounters returns packet and byte counters of the rule tagged with the given comment.
+// It parses rules listed by `iptables -S <chain> -v` or `iptables-save -c`.
+func RuleCounters(lines []string, comment string) (packets, bytes uint64, err error) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if !hasComment(fields, comment) {
+			continue
+		}
+
+		// iptables-save -c prefixes rules with [packets:bytes].
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "[") && strings.HasSuffix(fields[0], "]") {
+			counters := strings.SplitN(strings.Trim(fields[0], "[]"), ":", 2)
+			if len(counters) == 2 {
+				return parseCounters(counters[0], counters[1])
+			}
+		}
+
+		// iptables -S -v lists counters as -c packets bytes.
+		for i := 0; i < len(fields)-2; i++ {
+			if fields[i] == "-c" {
+				return parseCounters(fields[i+1], fields[i+2])
+			}
+		}
+		return 0, 0, fmt.Errorf("no counters in rule: %s", line)
+	}
+	return 0, 0, ErrRuleNotFound
+}
+
+// RulesWithCommentPrefix returns the rules which comment starts with the given prefix.
+// It parses rules listed by `iptables -S <chain> --table <table>`.
+func RulesWithCommentPrefix(lines []string, table, prefix string) (rules []Rule) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "-A" || !strings.HasPrefix(commentOf(fields), prefix) {
+			continue
+		}
+
+		var spec []string
+		for i := 2; i < len(fields); i++ {
+			if fields[i] == "-c" && i+2 < len(fields) {
+				i += 2
+				continue
+			}
+			spec = append(spec, strings.Trim(fields[i], `"`))
+		}
+		rules = append(rules, AppendTo(fields[1]).RuleSpec(append(spec, "--table", table)...))
+	}
+	return rules
+}
+
+func hasComment(fields []string, comment string) bool {
+	return commentOf(fields) == comment
+}
+
+func commentOf(fields []string) string {
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "--comment" {
+			return strings.Trim(fields[i+1], `"`)
+		}
+	}
+	return ""
+}
+
+func parseCounters(packets, bytes string) (uint64, uint64, error) {
+	p, err := strconv.ParseUint(packets, 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to parse packet counter")
+	}
+	b, err := strconv.ParseUint(bytes, 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to parse byte counter")
+	}
+	return p, b, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleCounters(t *testing.T) {
+	listed := []string{
+		"-P FORWARD DROP",
+		"-A FORWARD -s 10.8.0.0/24 -m comment --comment \"myst:forward-out:10.8.0.0/24\" -c 120 98304 -j ACCEPT",
+		"-A FORWARD -d 10.8.0.0/24 -m comment --comment myst:forward-in:10.8.0.0/24 -c 7 420 -j ACCEPT",
+		"-A FORWARD -d 10.9.0.0/24 -m comment --comment myst:broken -j ACCEPT",
+	}
+
+	packets, bytes, err := RuleCounters(listed, "myst:forward-out:10.8.0.0/24")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(120), packets)
+	assert.Equal(t, uint64(98304), bytes)
+
+	packets, bytes, err = RuleCounters(listed, "myst:forward-in:10.8.0.0/24")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), packets)
+	assert.Equal(t, uint64(420), bytes)
+
+	_, _, err = RuleCounters(listed, "myst:broken")
+	assert.Error(t, err)
+
+	_, _, err = RuleCounters(listed, "myst:missing")
+	assert.Equal(t, ErrRuleNotFound, err)
+
+	saved := []string{
+		"*nat",
+		":POSTROUTING ACCEPT [0:0]",
+		"[15:1200] -A POSTROUTING -s 10.8.0.0/24 ! -d 10.8.0.0/24 -m comment --comment \"myst:snat:10.8.0.0/24\" -j SNAT --to-source 1.2.3.4",
+		"COMMIT",
+	}
+	packets, bytes, err = RuleCounters(saved, "myst:snat:10.8.0.0/24")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(15), packets)
+	assert.Equal(t, uint64(1200), bytes)
+}
+
+func TestRule_WithComment(t *testing.T) {
+	rule := AppendTo("POSTROUTING").RuleSpec("--source", "10.8.0.0/24", "--table", "nat").WithComment("myst:snat")
+
+	assert.Equal(t, "myst:snat", rule.Comment())
+	assert.Equal(t, "nat", rule.Table())
+	assert.Equal(t, "POSTROUTING", rule.Chain())
+	assert.Equal(t, []string{"-A", "POSTROUTING", "--source", "10.8.0.0/24", "--table", "nat", "-m", "comment", "--comment", "myst:snat"}, rule.ApplyArgs())
+	assert.Equal(t, "filter", AppendTo("FORWARD").Table())
+}
+
+func TestRulesWithCommentPrefix(t *testing.T) {
+	listed := []string{
+		"-P FORWARD DROP",
+		"-A FORWARD -s 10.8.0.0/24 -m comment --comment \"myst:forward-out:10.8.0.0/24\" -j ACCEPT",
+		"-A FORWARD -s 172.17.0.0/16 -m comment --comment docker -j ACCEPT",
+		"-A FORWARD -d 10.8.0.0/24 -m comment --comment myst:forward-in:10.8.0.0/24 -c 7 420 -j ACCEPT",
+		"-A FORWARD -d 10.9.0.0/24 -j ACCEPT",
+	}
+
+	rules := RulesWithCommentPrefix(listed, "filter", "myst:")
+
+	assert.Len(t, rules, 2)
+	assert.Equal(t, []string{"-D", "FORWARD", "-s", "10.8.0.0/24", "-m", "comment", "--comment", "myst:forward-out:10.8.0.0/24", "-j", "ACCEPT", "--table", "filter"}, rules[0].RemoveArgs())
+	assert.Equal(t, "myst:forward-in:10.8.0.0/24", rules[1].Comment())
+	assert.Equal(t, "FORWARD", rules[1].Chain())
+}
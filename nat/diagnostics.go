@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const maxRecordedErrors = 10
+
+// NATDiagnostics is a point-in-time snapshot of the NAT service state.
+type NATDiagnostics struct {
+	Backend           string   `json:"backend"`
+	BackendPath       string   `json:"backend_path,omitempty"`
+	BackendVersion    string   `json:"backend_version,omitempty"`
+	IPForward         bool     `json:"ip_forward"`
+	Rules             []string `json:"rules"`
+	ProtectedNetworks []string `json:"protected_networks"`
+	LastErrors        []string `json:"last_errors"`
+}
+
+// errorLog keeps the most recent NAT errors for diagnostics.
+type errorLog struct {
+	mu   sync.Mutex
+	errs []string
+}
+
+func (l *errorLog) add(err error) {
+	if err == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.errs = append(l.errs, time.Now().UTC().Format(time.RFC3339)+" "+err.Error())
+	if len(l.errs) > maxRecordedErrors {
+		l.errs = l.errs[len(l.errs)-maxRecordedErrors:]
+	}
+}
+
+func (l *errorLog) list() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]string{}, l.errs...)
+}
+
+// backendVersion returns the `--version` output of the backend binary, e.g.
+// "iptables v1.8.7 (nf_tables)", which tells apart legacy and nftables based tools.
+func backendVersion(factory CommandFactory, path string) string {
+	out, err := factory(path, "--version").CombinedOutput()
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed to detect version of %s", path)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// protectedNetworkStrings returns networks which are actually protected, i.e. IPv4 ones.
+func protectedNetworkStrings() (nets []string) {
+	for _, ipNet := range protectedNetworksV4() {
+		nets = append(nets, ipNet.String())
+	}
+	return nets
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// errorLogInterval is how often a repeated identical NAT error is logged.
+const errorLogInterval = time.Minute
+
+// errorThrottle suppresses repeated identical errors, so e.g. a rule failing
+// on every reconciliation does not flood the log.
+type errorThrottle struct {
+	mu   sync.Mutex
+	seen map[string]*throttledError
+}
+
+type throttledError struct {
+	level      zerolog.Level
+	err        error
+	msg        string
+	loggedAt   time.Time
+	suppressed int
+}
+
+// allow reports whether the error should be logged now and how many identical
+// errors were suppressed since it was logged last time. Other errors not seen
+// for errorLogInterval are forgotten, the ones with suppressed repeats are
+// returned so their summary can be reported.
+func (t *errorThrottle) allow(level zerolog.Level, err error, msg string, now time.Time) (ok bool, suppressed int, stale []throttledError) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := msg + ": " + err.Error()
+	for k, e := range t.seen {
+		if k == key || now.Sub(e.loggedAt) < errorLogInterval {
+			continue
+		}
+		if e.suppressed > 0 {
+			stale = append(stale, *e)
+		}
+		delete(t.seen, k)
+	}
+
+	if t.seen == nil {
+		t.seen = make(map[string]*throttledError)
+	}
+	seen, ok := t.seen[key]
+	if !ok {
+		t.seen[key] = &throttledError{level: level, err: err, msg: msg, loggedAt: now}
+		return true, 0, stale
+	}
+	if now.Sub(seen.loggedAt) < errorLogInterval {
+		seen.suppressed++
+		return false, 0, stale
+	}
+
+	suppressed = seen.suppressed
+	seen.loggedAt, seen.suppressed = now, 0
+	return true, suppressed, stale
+}
+
+// flush reports errors which repeats were suppressed and forgets all errors.
+func (t *errorThrottle) flush() {
+	t.mu.Lock()
+	var pending []throttledError
+	for _, e := range t.seen {
+		if e.suppressed > 0 {
+			pending = append(pending, *e)
+		}
+	}
+	t.seen = nil
+	t.mu.Unlock()
+
+	for _, e := range pending {
+		logRepeated(e.level, e.err, e.msg, e.suppressed)
+	}
+}
+
+// warn logs the error at warn level unless an identical one was logged recently.
+func (t *errorThrottle) warn(err error, msg string) {
+	t.log(zerolog.WarnLevel, err, msg)
+}
+
+// error logs the error at error level unless an identical one was logged recently.
+func (t *errorThrottle) error(err error, msg string) {
+	t.log(zerolog.ErrorLevel, err, msg)
+}
+
+func (t *errorThrottle) log(level zerolog.Level, err error, msg string) {
+	ok, suppressed, stale := t.allow(level, err, msg, time.Now())
+	for _, e := range stale {
+		logRepeated(e.level, e.err, e.msg, e.suppressed)
+	}
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		logRepeated(level, err, msg, suppressed)
+		return
+	}
+	log.WithLevel(level).Err(err).Msg(msg)
+}
+
+func logRepeated(level zerolog.Level, err error, msg string, times int) {
+	log.WithLevel(level).Err(err).Msgf("%s (repeated %d times since last report)", msg, times)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ErrorThrottle_LogsRepeatedErrorOnce(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = original }()
+
+	var throttle errorThrottle
+	for i := 0; i < 3; i++ {
+		throttle.warn(errors.New("iptables not found"), "Failed to restore MYST iptables chain")
+	}
+	throttle.warn(errors.New("permission denied"), "Failed to restore MYST iptables chain")
+
+	assert.Equal(t, 2, strings.Count(buf.String(), "Failed to restore MYST iptables chain"))
+}
+
+func Test_ServiceIPTables_ThrottlesSetupErrors(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = original }()
+	mockIPTablesExec(t)
+	iptablesExec = func(args ...string) error {
+		return errors.New("iptables: Resource temporarily unavailable")
+	}
+
+	svc := &serviceIPTables{}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	for i := 0; i < 3; i++ {
+		_, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "Error detected, clearing up rules"))
+}
+
+func Test_ErrorThrottle_SummarizesSuppressedErrors(t *testing.T) {
+	var throttle errorThrottle
+	now := time.Now()
+	failed := errors.New("failed")
+
+	ok, _, _ := throttle.allow(zerolog.WarnLevel, failed, "msg", now)
+	assert.True(t, ok)
+	ok, _, _ = throttle.allow(zerolog.WarnLevel, failed, "msg", now.Add(time.Second))
+	assert.False(t, ok)
+	ok, _, _ = throttle.allow(zerolog.WarnLevel, failed, "msg", now.Add(2*time.Second))
+	assert.False(t, ok)
+
+	ok, suppressed, _ := throttle.allow(zerolog.WarnLevel, failed, "msg", now.Add(errorLogInterval))
+	assert.True(t, ok)
+	assert.Equal(t, 2, suppressed)
+
+	ok, _, _ = throttle.allow(zerolog.WarnLevel, failed, "other", now.Add(errorLogInterval))
+	assert.True(t, ok)
+}
+
+func Test_ErrorThrottle_ForgetsStaleErrors(t *testing.T) {
+	var throttle errorThrottle
+	now := time.Now()
+	failed := errors.New("failed")
+
+	throttle.allow(zerolog.WarnLevel, failed, "burst", now)
+	throttle.allow(zerolog.WarnLevel, failed, "burst", now.Add(time.Second))
+	throttle.allow(zerolog.WarnLevel, failed, "once", now)
+
+	_, _, stale := throttle.allow(zerolog.WarnLevel, failed, "other", now.Add(errorLogInterval+time.Second))
+
+	assert.Equal(t, []throttledError{{level: zerolog.WarnLevel, err: failed, msg: "burst", loggedAt: now, suppressed: 1}}, stale)
+	assert.Len(t, throttle.seen, 1)
+}
+
+func Test_ErrorThrottle_FlushReportsSuppressedErrors(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = original }()
+
+	var throttle errorThrottle
+	for i := 0; i < 3; i++ {
+		throttle.warn(errors.New("iptables not found"), "Failed to restore MYST iptables chain")
+	}
+	throttle.flush()
+
+	assert.Contains(t, buf.String(), "Failed to restore MYST iptables chain (repeated 2 times since last report)")
+	assert.Empty(t, throttle.seen)
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package event
+
+import "time"
+
+// AppTopicRuleReconciled the topic that NAT rule reconciliation events are published on
+const AppTopicRuleReconciled = "NAT rule reconciled"
+
+// Reconciliation actions.
+const (
+	// ActionRestored is reported when a missing rule or chain was set up again.
+	ActionRestored = "restored"
+)
+
+// RuleReconciled represents NAT/Firewall state healed by the reconciliation,
+// which means something other than the node changed the firewall.
+type RuleReconciled struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// AppTopicRuleExpired the topic that NAT rule expiry events are published on
+const AppTopicRuleExpired = "NAT rule expired"
+
+// RuleExpired represents a NAT/Firewall rule removed after its TTL passed
+// without being deleted by the code which set it up.
+type RuleExpired struct {
+	Rule string        `json:"rule"`
+	TTL  time.Duration `json:"ttl"`
+}
@@ -17,6 +17,8 @@
 
 package event
 
+import "time"
+
 // AppTopicTraversal the topic that traversal events are published on
 const AppTopicTraversal = "Traversal"
 
@@ -32,8 +34,30 @@ func BuildFailureEvent(id, stage string, err error) Event {
 
 // Event represents a NAT traversal related event
 type Event struct {
-	ID         string `json:"id"`
-	Stage      string `json:"stage"`
-	Successful bool   `json:"successful"`
-	Error      error  `json:"error,omitempty"`
+	ID          string `json:"id"`
+	Stage       string `json:"stage"`
+	ServiceType string `json:"service_type,omitempty"`
+	DoubleNAT   bool   `json:"double_nat,omitempty"`
+	Successful  bool   `json:"successful"`
+	Error       error  `json:"error,omitempty"`
+	// Phases holds durations of traversal phases, e.g. socket setup or punching.
+	Phases map[string]time.Duration `json:"phases,omitempty"`
+}
+
+// WithServiceType returns a copy of the event labeled with the service type.
+func (e Event) WithServiceType(serviceType string) Event {
+	e.ServiceType = serviceType
+	return e
+}
+
+// WithDoubleNAT returns a copy of the event marked with the double NAT signal.
+func (e Event) WithDoubleNAT(doubleNAT bool) Event {
+	e.DoubleNAT = doubleNAT
+	return e
+}
+
+// WithPhases returns a copy of the event with durations of traversal phases.
+func (e Event) WithPhases(phases map[string]time.Duration) Event {
+	e.Phases = phases
+	return e
 }
@@ -21,10 +21,11 @@ import (
 	"os/exec"
 
 	"github.com/mysteriumnetwork/node/config"
+	"github.com/mysteriumnetwork/node/eventbus"
 )
 
 // NewService returns fake nat service since there are no iptables on darwin
-func NewService() NATService {
+func NewService(_ eventbus.Publisher) NATService {
 	if config.GetBool(config.FlagUserspace) {
 		return &serviceNoop{}
 	}
@@ -21,21 +21,28 @@ import (
 	"os/exec"
 
 	"github.com/mysteriumnetwork/node/config"
+	"github.com/mysteriumnetwork/node/eventbus"
 )
 
-// NewService returns linux os specific nat service based on ip tables
-func NewService() NATService {
+// NewService returns linux os specific nat service based on ip tables.
+// Publisher is notified about rules restored by the reconciliation.
+func NewService(publisher eventbus.Publisher) NATService {
 	if config.GetBool(config.FlagUserspace) {
 		return &serviceNoop{}
 	}
+	commandFactory := func(name string, arg ...string) Command {
+		return exec.Command(name, arg...)
+	}
 	return &serviceIPTables{
 		ipForward: serviceIPForward{
-			CommandFactory: func(name string, arg ...string) Command {
-				return exec.Command(name, arg...)
-			},
+			CommandFactory: commandFactory,
 			CommandEnable:  []string{"sudo", "/sbin/sysctl", "-w", "net.ipv4.ip_forward=1"},
 			CommandDisable: []string{"sudo", "/sbin/sysctl", "-w", "net.ipv4.ip_forward=0"},
 			CommandRead:    []string{"/sbin/sysctl", "-n", "net.ipv4.ip_forward"},
 		},
+		reconcileInterval: config.GetDuration(config.FlagFirewallReconcileInterval),
+		verifyTeardown:    config.GetBool(config.FlagFirewallVerifyTeardown),
+		maxRules:          config.GetInt(config.FlagFirewallMaxRules),
+		publisher:         publisher,
 	}
 }
@@ -19,11 +19,12 @@ package nat
 
 import (
 	"github.com/mysteriumnetwork/node/config"
+	"github.com/mysteriumnetwork/node/eventbus"
 	"github.com/mysteriumnetwork/node/utils/cmdutil"
 )
 
 // NewService returns Windows OS specific NAT service based on Internet Connection Sharing (ICS).
-func NewService() NATService {
+func NewService(_ eventbus.Publisher) NATService {
 	if config.GetBool(config.FlagUserspace) {
 		return &serviceNoop{}
 	}
@@ -17,7 +17,12 @@
 
 package nat
 
-import "net"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
 
 // NATService routes internet traffic through provider and
 // sets up firewall rules for security
@@ -26,11 +31,78 @@ type NATService interface {
 	Setup(opts Options) (rules []interface{}, err error)
 	Del(rules []interface{}) error
 	Disable() error
+	Diagnostics() NATDiagnostics
 }
 
+// RuleManager is implemented by NAT services which can manage individual rules.
+// Backends without rule management return ErrRuleManagementUnsupported.
+type RuleManager interface {
+	// Validate checks that rules for the given options can be set up.
+	Validate(opts Options) error
+	// ReplaceAll makes the given rules the whole set of rules set up by the service.
+	ReplaceAll(rules []interface{}) error
+	// Diff compares rules set up by the service with the rules present in the system.
+	Diff() (missing, extra []interface{}, err error)
+	// RuleStats returns packet and byte counters of the given rule.
+	RuleStats(rule interface{}) (packets, bytes uint64, err error)
+	// FlushOwned deletes all rules owned by the node, including untracked ones.
+	FlushOwned() error
+	// SetRuleObserver sets observer notified after rules are added or removed.
+	SetRuleObserver(observer RuleObserver)
+	// SetOutboundIPResolver sets how the IP to NAT to is found when setup
+	// options do not specify it. Nil restores the default resolver.
+	SetOutboundIPResolver(resolver OutboundIPResolver)
+}
+
+var (
+	// ErrRuleManagementUnsupported indicates the NAT backend can not manage individual rules.
+	ErrRuleManagementUnsupported = errors.New("NAT rule management is not supported")
+	// ErrNATTargetNotLocal indicates the provider external IP to NAT to is not
+	// assigned to any local interface.
+	ErrNATTargetNotLocal = errors.New("NAT target is not a local address")
+	// ErrNATProtectedNetwork indicates the VPN network covers a network protected
+	// from access via VPN.
+	ErrNATProtectedNetwork = errors.New("VPN network covers a protected network")
+	// ErrNATRuleConflict indicates rules for the VPN network are already set up.
+	ErrNATRuleConflict = errors.New("NAT rules for the VPN network are already set up")
+	// ErrRuleLimitExceeded indicates setting up rules would exceed the maximum
+	// number of rules the NAT service may track.
+	ErrRuleLimitExceeded = errors.New("NAT rule limit exceeded")
+)
+
 // Options params to setup firewall/NAT rules.
 type Options struct {
 	VPNNetwork    net.IPNet
 	ProviderExtIP net.IP
 	DNSIP         net.IP
+	// OutInterface limits NAT to traffic leaving the given interface,
+	// e.g. a single WAN on multi-WAN hosts. Empty means any interface.
+	OutInterface string
+	// TTL, if set, makes the rules removed automatically once it passes,
+	// unless they are deleted earlier, e.g. in case session code crashed.
+	// Zero keeps the rules until they are deleted.
+	TTL time.Duration
+}
+
+// normalize returns options with the VPN network in its canonical form,
+// e.g. 10.0.0.5/24 becomes 10.0.0.0/24.
+func (opts Options) normalize() (Options, error) {
+	_, bits := opts.VPNNetwork.Mask.Size()
+	if opts.VPNNetwork.IP == nil || bits == 0 {
+		return opts, fmt.Errorf("invalid VPN network: %s", opts.VPNNetwork.String())
+	}
+
+	ip := opts.VPNNetwork.IP.Mask(opts.VPNNetwork.Mask)
+	if ip == nil {
+		return opts, fmt.Errorf("invalid VPN network: %s", opts.VPNNetwork.String())
+	}
+
+	opts.VPNNetwork = net.IPNet{IP: ip, Mask: opts.VPNNetwork.Mask}
+
+	if opts.OutInterface != "" {
+		if _, err := net.InterfaceByName(opts.OutInterface); err != nil {
+			return opts, fmt.Errorf("invalid outgoing interface %s: %w", opts.OutInterface, err)
+		}
+	}
+	return opts, nil
 }
@@ -0,0 +1,33 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+// RuleAction describes a change of NAT/Firewall rules.
+type RuleAction string
+
+const (
+	// RuleAdded is reported after rules are set up.
+	RuleAdded RuleAction = "added"
+	// RuleRemoved is reported after rules are deleted.
+	RuleRemoved RuleAction = "removed"
+)
+
+// RuleObserver is notified after NAT/Firewall rules are successfully added or removed.
+// It is called synchronously, but outside of NAT service locks, so a slow observer
+// delays only the caller which changed the rules.
+type RuleObserver func(action RuleAction, rules []interface{})
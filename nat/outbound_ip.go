@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// outboundIPCheckAddress is the public IPv4 address the default resolver
+// routes to when finding the outbound IP. Nothing is sent to it, connecting
+// a UDP socket only selects the route. Hosts which can not route to it should
+// set their own resolver with RuleManager.SetOutboundIPResolver.
+const outboundIPCheckAddress = "8.8.8.8:53"
+
+// OutboundIPResolver resolves the local IP address used to reach the internet.
+type OutboundIPResolver func() (net.IP, error)
+
+// dialOutboundIP is the default OutboundIPResolver. It finds the outbound IP by connecting a UDP socket to a public
+// address and reading its local address.
+func dialOutboundIP() (net.IP, error) {
+	conn, err := net.Dial("udp4", outboundIPCheckAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to determine outbound IP")
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -20,17 +20,43 @@ package nat
 import (
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/mysteriumnetwork/node/config"
 	"github.com/rs/zerolog/log"
 )
 
+// protectedNetworksPrivate is a protected networks keyword
+// which expands to the standard private IPv4 networks.
+const protectedNetworksPrivate = "private"
+
+var privateNetworks = []string{
+	// RFC1918
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	// Link-local
+	"169.254.0.0/16",
+}
+
+// warnIPv6Once makes the unsupported IPv6 protected networks reported only once.
+var warnIPv6Once sync.Once
+
+// ProtectedNetworks returns networks which are protected from access via VPN.
+func ProtectedNetworks() []*net.IPNet {
+	return protectedNetworks()
+}
+
 func protectedNetworks() (nets []*net.IPNet) {
 	cfg := config.GetString(config.FlagFirewallProtectedNetworks)
 	if cfg == "" {
 		return nil
 	}
 	for _, s := range strings.Split(cfg, ",") {
+		if s == protectedNetworksPrivate {
+			nets = append(nets, parseNetworks(privateNetworks)...)
+			continue
+		}
 		_, ipNet, err := net.ParseCIDR(s)
 		if err != nil {
 			log.Error().Err(err).Msg("Could not parse protected network string")
@@ -40,3 +66,39 @@ func protectedNetworks() (nets []*net.IPNet) {
 	}
 	return nets
 }
+
+func parseNetworks(cidrs []string) (nets []*net.IPNet) {
+	for _, s := range cidrs {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			log.Error().Err(err).Msg("Could not parse network string")
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// protectedNetworksV4 returns IPv4 protected networks. NAT rules only forward
+// IPv4, so IPv6 networks get no rules and a warning is logged about them.
+func protectedNetworksV4() []*net.IPNet {
+	v4, v6 := splitByFamily(protectedNetworks())
+	if len(v6) > 0 {
+		warnIPv6Once.Do(func() {
+			log.Warn().Msgf("IPv6 protected networks are not supported, no rules are set up for: %v", v6)
+		})
+	}
+	return v4
+}
+
+// splitByFamily splits networks into IPv4 and IPv6 ones.
+func splitByFamily(nets []*net.IPNet) (v4, v6 []*net.IPNet) {
+	for _, ipNet := range nets {
+		if ipNet.IP.To4() != nil {
+			v4 = append(v4, ipNet)
+		} else {
+			v6 = append(v6, ipNet)
+		}
+	}
+	return v4, v6
+}
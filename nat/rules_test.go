@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mysteriumnetwork/node/config"
+)
+
+func Test_protectedNetworks(t *testing.T) {
+	defer config.Current.RemoveUser(config.FlagFirewallProtectedNetworks.Name)
+
+	config.Current.SetUser(config.FlagFirewallProtectedNetworks.Name, "127.0.0.0/8,private,fc00::/7,invalid")
+	v4, v6 := splitByFamily(protectedNetworks())
+
+	assert.Equal(t, []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16"}, networkStrings(v4))
+	assert.Equal(t, []string{"fc00::/7"}, networkStrings(v6))
+}
+
+func Test_protectedNetworksV4(t *testing.T) {
+	defer config.Current.RemoveUser(config.FlagFirewallProtectedNetworks.Name)
+
+	config.Current.SetUser(config.FlagFirewallProtectedNetworks.Name, "10.0.0.0/8,fc00::/7,192.168.0.0/16")
+
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.0.0/16"}, networkStrings(protectedNetworksV4()))
+}
+
+func networkStrings(nets []*net.IPNet) (res []string) {
+	for _, ipNet := range nets {
+		res = append(res, ipNet.String())
+	}
+	return res
+}
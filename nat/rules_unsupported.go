@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+// rulesUnsupported implements RuleManager for NAT backends which manage
+// their rules as a whole.
+type rulesUnsupported struct{}
+
+// Validate returns ErrRuleManagementUnsupported.
+func (rulesUnsupported) Validate(Options) error {
+	return ErrRuleManagementUnsupported
+}
+
+// ReplaceAll returns ErrRuleManagementUnsupported.
+func (rulesUnsupported) ReplaceAll([]interface{}) error {
+	return ErrRuleManagementUnsupported
+}
+
+// Diff returns ErrRuleManagementUnsupported.
+func (rulesUnsupported) Diff() (missing, extra []interface{}, err error) {
+	return nil, nil, ErrRuleManagementUnsupported
+}
+
+// RuleStats returns ErrRuleManagementUnsupported.
+func (rulesUnsupported) RuleStats(interface{}) (packets, bytes uint64, err error) {
+	return 0, 0, ErrRuleManagementUnsupported
+}
+
+// FlushOwned returns ErrRuleManagementUnsupported.
+func (rulesUnsupported) FlushOwned() error {
+	return ErrRuleManagementUnsupported
+}
+
+// SetRuleObserver does nothing, since rules are not reported.
+func (rulesUnsupported) SetRuleObserver(RuleObserver) {}
+
+// SetOutboundIPResolver does nothing, since the outbound IP is not used.
+func (rulesUnsupported) SetOutboundIPResolver(OutboundIPResolver) {}
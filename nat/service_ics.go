@@ -63,6 +63,8 @@ func getDisableSharingScript(ifaceName string) string {
 }
 
 type serviceICS struct {
+	rulesUnsupported
+
 	mu                  sync.Mutex
 	activeInternalIface string
 	remoteAccessStatus  string
@@ -212,6 +214,21 @@ func (ics *serviceICS) Disable() error {
 	return result.Error()
 }
 
+// Diagnostics returns NAT service state snapshot.
+func (ics *serviceICS) Diagnostics() NATDiagnostics {
+	ics.mu.Lock()
+	defer ics.mu.Unlock()
+
+	var rules []string
+	if ics.activeInternalIface != "" {
+		rules = append(rules, "sharing enabled for "+ics.activeInternalIface)
+	}
+	return NATDiagnostics{
+		Backend: "ics",
+		Rules:   rules,
+	}
+}
+
 func (ics *serviceICS) getPublicInterfaceName() (string, error) {
 	out, err := ics.powerShell(`Get-WmiObject -Class Win32_IP4RouteTable | where { $_.destination -eq '0.0.0.0' -and $_.mask -eq '0.0.0.0'} | foreach { $_.InterfaceIndex }`)
 	if err != nil {
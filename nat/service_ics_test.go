@@ -29,6 +29,7 @@ import (
 )
 
 var _ NATService = &serviceICS{}
+var _ RuleManager = &serviceICS{}
 
 func mockedICS(powerShell func(cmd string) ([]byte, error)) *serviceICS {
 	return &serviceICS{
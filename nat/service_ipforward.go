@@ -18,6 +18,7 @@
 package nat
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -69,10 +70,28 @@ func (service *serviceIPForward) Disable() {
 }
 
 func (service *serviceIPForward) Enabled() bool {
+	enabled, err := service.IsEnabled()
+	if err != nil {
+		log.Warn().Err(err).Msgf("Failed to check IP forwarding status: %v", service.CommandRead[1:])
+	}
+	return enabled
+}
+
+// IsEnabled reads the IP forwarding sysctl and reports whether forwarding is on.
+// If the read command fails, the state it printed is returned along with the error.
+func (service *serviceIPForward) IsEnabled() (bool, error) {
 	output, err := service.CommandFactory(service.CommandRead[0], service.CommandRead[1:]...).Output()
+	state := strings.TrimSpace(string(output))
 	if err != nil {
-		log.Warn().Err(err).Msgf("Failed to check IP forwarding status: %v Cmd output: %v", service.CommandRead[1:], string(output))
+		return state == "1", fmt.Errorf("failed to read IP forwarding state: %w, cmd output: %v", err, state)
 	}
 
-	return strings.TrimSpace(string(output)) == "1"
+	switch state {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected IP forwarding state: %q", state)
+	}
 }
@@ -69,6 +69,39 @@ func Test_ServiceIPForward_Enabled(t *testing.T) {
 	assert.True(t, service.Enabled())
 }
 
+func Test_ServiceIPForward_IsEnabled(t *testing.T) {
+	mc := &mockCommand{
+		OutputRes: []byte("1\n"),
+	}
+	mf := &mockCommandFactory{
+		MockCommand: mc,
+	}
+	service := &serviceIPForward{
+		CommandFactory: mf.Create,
+		CommandRead:    []string{"doesnt", "matter"},
+	}
+
+	enabled, err := service.IsEnabled()
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	mc.OutputRes = []byte("0\n")
+	enabled, err = service.IsEnabled()
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	mc.OutputRes = []byte("calm waters")
+	enabled, err = service.IsEnabled()
+	assert.Error(t, err)
+	assert.False(t, enabled)
+
+	mc.OutputError = errors.New("mass panic")
+	mc.OutputRes = []byte("1")
+	enabled, err = service.IsEnabled()
+	assert.ErrorIs(t, err, mc.OutputError)
+	assert.True(t, enabled)
+}
+
 func Test_ServiceIPForward_Enable(t *testing.T) {
 	mc := &mockCommand{
 		CombinedOutputRes: []byte("1"),
@@ -19,14 +19,19 @@ package nat
 
 import (
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 
 	"github.com/mysteriumnetwork/node/config"
+	"github.com/mysteriumnetwork/node/eventbus"
 	"github.com/mysteriumnetwork/node/firewall/iptables"
+	"github.com/mysteriumnetwork/node/nat/event"
 	"github.com/mysteriumnetwork/node/utils"
 	"github.com/mysteriumnetwork/node/utils/cmdutil"
 )
@@ -35,8 +40,59 @@ type serviceIPTables struct {
 	mu        sync.Mutex
 	rules     []iptables.Rule
 	ipForward serviceIPForward
+	errs      errorLog
+	throttle  errorThrottle
+
+	reconcileInterval time.Duration
+	reconcileStop     chan struct{}
+	reconcileDone     chan struct{}
+
+	// verifyTeardown makes Disable check that removed rules are really gone.
+	verifyTeardown bool
+	// maxRules limits the number of tracked rules. Zero means unlimited.
+	maxRules int
+	// protection holds rules blackholing protected networks, set up by Enable.
+	// They are tracked along with the rest, but never replaced by ReplaceAll.
+	protection []iptables.Rule
+	// backendVersion is the version of iptables, detected on first use.
+	backendVersion     string
+	backendVersionOnce sync.Once
+
+	observerMu sync.Mutex
+	observer   RuleObserver
+
+	// resolveOutboundIP finds the IP to SNAT to when setup options do not
+	// specify it. The resolved IP is cached for outboundIPCacheTTL, or until
+	// a setup fails.
+	resolveOutboundIP  OutboundIPResolver
+	outboundIP         net.IP
+	outboundIPResolved time.Time
+
+	// publisher, if set, is notified about rules restored by the reconciliation.
+	publisher eventbus.Publisher
+
+	// expiries holds rules set up with a TTL, keyed by their apply args.
+	expiries map[string]ruleExpiry
+	// swept holds rules removed by the expiry sweep, so deleting them later
+	// does not fail.
+	swept         map[string]struct{}
+	sweepInterval time.Duration
+	sweepStop     chan struct{}
+	sweepDone     chan struct{}
 }
 
+type ruleExpiry struct {
+	at  time.Time
+	ttl time.Duration
+}
+
+// defaultSweepInterval is how often rules set up with a TTL are checked for expiry.
+const defaultSweepInterval = time.Second
+
+// outboundIPCacheTTL is how long the resolved outbound IP is reused, so rules
+// follow it when the provider moves to another network.
+const outboundIPCacheTTL = 5 * time.Minute
+
 const (
 	chainMyst        = "MYST"
 	chainInput       = "INPUT"
@@ -45,8 +101,46 @@ const (
 	chainPostRouting = "POSTROUTING"
 )
 
+// ownedCommentPrefix tags rules set up by the node, so they can be found in the kernel.
+const ownedCommentPrefix = "myst:"
+
+// ownedChains lists chains holding rules tagged with ownedCommentPrefix.
+var ownedChains = []struct{ table, chain string }{
+	{"nat", chainPreRouting},
+	{"nat", chainMyst},
+	{"nat", chainPostRouting},
+	{"filter", chainForward},
+}
+
+// SetRuleObserver sets observer notified after rules are added or removed.
+func (svc *serviceIPTables) SetRuleObserver(observer RuleObserver) {
+	svc.observerMu.Lock()
+	defer svc.observerMu.Unlock()
+
+	svc.observer = observer
+}
+
+func (svc *serviceIPTables) notify(action RuleAction, rules []iptables.Rule) {
+	svc.observerMu.Lock()
+	observer := svc.observer
+	svc.observerMu.Unlock()
+
+	if observer != nil && len(rules) > 0 {
+		observer(action, untypedIptRules(rules))
+	}
+}
+
 // Setup sets NAT/Firewall rules for the given NATOptions.
 func (svc *serviceIPTables) Setup(opts Options) (appliedRules []interface{}, err error) {
+	rules, err := svc.setup(opts)
+	if err != nil {
+		return nil, err
+	}
+	svc.notify(RuleAdded, rules)
+	return untypedIptRules(rules), nil
+}
+
+func (svc *serviceIPTables) setup(opts Options) (appliedRules []iptables.Rule, err error) {
 	log.Info().Msg("Setting up NAT/Firewall rules")
 	svc.mu.Lock()
 	defer svc.mu.Unlock()
@@ -57,40 +151,355 @@ func (svc *serviceIPTables) Setup(opts Options) (appliedRules []interface{}, err
 		if err == nil {
 			return
 		}
-		log.Warn().Msg("Error detected, clearing up rules that were already setup")
+		svc.errs.add(err)
+		// The outbound IP might be the reason, resolve it again next time.
+		svc.outboundIP = nil
+		svc.throttle.warn(err, "Error detected, clearing up rules that were already setup")
 		for _, rule := range applied {
 			if err := svc.removeRule(rule); err != nil {
-				log.Error().Err(err).Msg("Could not remove rule")
+				svc.throttle.error(err, "Could not remove rule")
 			}
 		}
 	}()
 
-	for _, rule := range makeIPTablesRules(opts) {
-		if err := svc.applyRule(rule); err != nil {
+	opts, err = opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if opts.ProviderExtIP == nil || opts.ProviderExtIP.IsUnspecified() {
+		if opts.ProviderExtIP, err = svc.providerExtIP(); err != nil {
 			return nil, err
 		}
+	}
+
+	rules := makeIPTablesRules(opts)
+	if err := svc.checkRuleLimit(svc.rules, rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if err := svc.applyRule(rule); err != nil {
+			return nil, fmt.Errorf("failed to apply rule %v: %w", rule.ApplyArgs(), err)
+		}
 		applied = append(applied, rule)
 	}
+	svc.setExpiry(applied, opts.TTL)
 	log.Info().Msg("Setting up NAT/Firewall rules... done")
-	return untypedIptRules(applied), nil
+	return applied, nil
+}
+
+// setExpiry makes rules expire after ttl, or keeps them forever if ttl is zero.
+// Must be called with svc.mu held.
+func (svc *serviceIPTables) setExpiry(rules []iptables.Rule, ttl time.Duration) {
+	if ttl <= 0 {
+		for _, rule := range rules {
+			delete(svc.expiries, ruleID(rule))
+		}
+		return
+	}
+
+	if svc.expiries == nil {
+		svc.expiries = make(map[string]ruleExpiry)
+	}
+	at := time.Now().Add(ttl)
+	for _, rule := range rules {
+		svc.expiries[ruleID(rule)] = ruleExpiry{at: at, ttl: ttl}
+	}
+	svc.startSweepLocked()
+}
+
+func ruleID(rule iptables.Rule) string {
+	return strings.Join(rule.ApplyArgs(), " ")
+}
+
+// startSweepLocked starts removing expired rules in the background.
+// Must be called with svc.mu held.
+func (svc *serviceIPTables) startSweepLocked() {
+	if svc.sweepStop != nil {
+		return
+	}
+	if config.GetBool(config.FlagUserMode) || config.GetBool(config.FlagUserspace) {
+		return
+	}
+	interval := svc.sweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	svc.sweepStop = make(chan struct{})
+	svc.sweepDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(interval):
+				svc.sweepExpired()
+			}
+		}
+	}(svc.sweepStop, svc.sweepDone)
+}
+
+func (svc *serviceIPTables) stopSweep() {
+	svc.mu.Lock()
+	stop, done := svc.sweepStop, svc.sweepDone
+	svc.sweepStop, svc.sweepDone = nil, nil
+	svc.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// sweepExpired removes rules which TTL passed.
+func (svc *serviceIPTables) sweepExpired() {
+	svc.mu.Lock()
+	now := time.Now()
+	var expired []iptables.Rule
+	var ttls []time.Duration
+	for _, rule := range append([]iptables.Rule{}, svc.rules...) {
+		expiry, ok := svc.expiries[ruleID(rule)]
+		if !ok || now.Before(expiry.at) {
+			continue
+		}
+		if err := svc.removeRule(rule); err != nil {
+			svc.errs.add(err)
+			svc.throttle.warn(err, fmt.Sprintf("Failed to remove expired NAT/Firewall rule: %v", rule.ApplyArgs()))
+			continue
+		}
+		log.Info().Msgf("Removed expired NAT/Firewall rule: %v", rule.ApplyArgs())
+		if svc.swept == nil {
+			svc.swept = make(map[string]struct{})
+		}
+		svc.swept[ruleID(rule)] = struct{}{}
+		expired = append(expired, rule)
+		ttls = append(ttls, expiry.ttl)
+	}
+	svc.mu.Unlock()
+
+	if len(expired) == 0 {
+		return
+	}
+	svc.notify(RuleRemoved, expired)
+	if svc.publisher == nil {
+		return
+	}
+	for i, rule := range expired {
+		svc.publisher.Publish(event.AppTopicRuleExpired, event.RuleExpired{Rule: ruleID(rule), TTL: ttls[i]})
+	}
+}
+
+// checkRuleLimit fails if tracking the given rules would exceed the limit.
+// Protected network rules set up by Enable are not counted.
+func (svc *serviceIPTables) checkRuleLimit(ruleSets ...[]iptables.Rule) error {
+	if svc.maxRules <= 0 {
+		return nil
+	}
+
+	var total int
+	for _, rules := range ruleSets {
+		for _, rule := range rules {
+			if !containsRule(svc.protection, rule) {
+				total++
+			}
+		}
+	}
+	if total > svc.maxRules {
+		return fmt.Errorf("%w: %d rules would be tracked, limit is %d", ErrRuleLimitExceeded, total, svc.maxRules)
+	}
+	return nil
+}
+
+// SetOutboundIPResolver sets how the IP to NAT to is found when setup options
+// do not specify it. Nil restores the default resolver. The cached IP is dropped.
+func (svc *serviceIPTables) SetOutboundIPResolver(resolver OutboundIPResolver) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	svc.resolveOutboundIP = resolver
+	svc.outboundIP = nil
+}
+
+// providerExtIP returns the cached outbound IP, resolving it if the cache is empty or stale.
+func (svc *serviceIPTables) providerExtIP() (net.IP, error) {
+	if svc.outboundIP != nil && time.Since(svc.outboundIPResolved) < outboundIPCacheTTL {
+		return svc.outboundIP, nil
+	}
+
+	resolve := svc.resolveOutboundIP
+	if resolve == nil {
+		resolve = dialOutboundIP
+	}
+	ip, err := resolve()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve provider external IP: %w", err)
+	}
+	log.Info().Msgf("Resolved provider external IP for NAT: %s", ip)
+	svc.outboundIP = ip
+	svc.outboundIPResolved = time.Now()
+	return ip, nil
+}
+
+// Validate checks that rules for the given options can be set up, without
+// touching the firewall or the tracked rules: the VPN network must be valid and
+// not cover a protected network, the SNAT target must be a local address and
+// rules for the VPN network must not be set up already.
+func (svc *serviceIPTables) Validate(opts Options) error {
+	opts, err := opts.normalize()
+	if err != nil {
+		return err
+	}
+
+	if ip := opts.ProviderExtIP; ip != nil && !ip.IsUnspecified() && !isLocalIP(ip) {
+		return fmt.Errorf("%w: %s", ErrNATTargetNotLocal, ip)
+	}
+
+	vpnOnes, _ := opts.VPNNetwork.Mask.Size()
+	for _, protected := range protectedNetworks() {
+		protectedOnes, _ := protected.Mask.Size()
+		if opts.VPNNetwork.Contains(protected.IP) && vpnOnes <= protectedOnes {
+			return fmt.Errorf("%w: %s covers %s", ErrNATProtectedNetwork, opts.VPNNetwork.String(), protected)
+		}
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	for _, rule := range makeIPTablesRules(opts) {
+		if comment := rule.Comment(); comment != "" && svc.tracksComment(comment) {
+			return fmt.Errorf("%w: %s", ErrNATRuleConflict, comment)
+		}
+	}
+	return nil
+}
+
+func isLocalIP(ip net.IP) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to list interface addresses")
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 // Del removes given NAT/Firewall rules that were previously set up.
 func (svc *serviceIPTables) Del(rules []interface{}) (err error) {
+	removed, err := svc.del(typedIptRules(rules))
+	svc.notify(RuleRemoved, removed)
+	return err
+}
+
+func (svc *serviceIPTables) del(rules []iptables.Rule) (removed []iptables.Rule, err error) {
 	log.Info().Msg("Deleting NAT/Firewall rules")
 	svc.mu.Lock()
 	defer svc.mu.Unlock()
 
 	errs := utils.ErrorCollection{}
-	for _, rule := range typedIptRules(rules) {
+	for _, rule := range rules {
 		log.Trace().Msgf("Deleting rule: %v", rule)
+		if _, ok := svc.swept[ruleID(rule)]; ok {
+			// Already removed by the expiry sweep.
+			delete(svc.swept, ruleID(rule))
+			continue
+		}
 		if err := svc.removeRule(rule); err != nil {
 			errs.Add(err)
+			continue
 		}
+		removed = append(removed, rule)
 	}
 	err = errs.Error()
-	log.Info().Err(err).Msg("Deleting NAT/Firewall rules... done")
-	return err
+	svc.errs.add(err)
+	if err != nil {
+		svc.throttle.warn(err, "Failed to delete NAT/Firewall rules")
+	}
+	log.Info().Msg("Deleting NAT/Firewall rules... done")
+	return removed, err
+}
+
+// ReplaceAll makes the given rules the whole tracked rule set. Rules which are
+// already tracked are left untouched, new ones are applied and the rest removed,
+// except for the protected network rules set up by Enable.
+// If any step fails, the changes made so far are rolled back.
+func (svc *serviceIPTables) ReplaceAll(rules []interface{}) error {
+	added, removed, err := svc.replaceAll(typedIptRules(rules))
+	if err != nil {
+		return err
+	}
+	svc.notify(RuleAdded, added)
+	svc.notify(RuleRemoved, removed)
+	return nil
+}
+
+func (svc *serviceIPTables) replaceAll(rules []iptables.Rule) (added, removed []iptables.Rule, err error) {
+	log.Info().Msg("Replacing NAT/Firewall rules")
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		svc.errs.add(err)
+		svc.throttle.warn(err, "Error detected, restoring replaced rules")
+		for _, rule := range removed {
+			if err := svc.applyRule(rule); err != nil {
+				svc.throttle.error(err, "Could not restore rule")
+			}
+		}
+		for _, rule := range added {
+			if err := svc.removeRule(rule); err != nil {
+				svc.throttle.error(err, "Could not remove rule")
+			}
+		}
+		added, removed = nil, nil
+	}()
+
+	if err := svc.checkRuleLimit(rules); err != nil {
+		return nil, nil, err
+	}
+
+	var stale []iptables.Rule
+	for _, rule := range svc.rules {
+		if !containsRule(rules, rule) && !containsRule(svc.protection, rule) {
+			stale = append(stale, rule)
+		}
+	}
+
+	// New rules go first, so traffic is not dropped while replacing.
+	for _, rule := range rules {
+		if containsRule(svc.rules, rule) {
+			continue
+		}
+		if err := svc.applyRule(rule); err != nil {
+			return added, removed, fmt.Errorf("failed to apply rule %v: %w", rule.ApplyArgs(), err)
+		}
+		added = append(added, rule)
+	}
+	for _, rule := range stale {
+		if err := svc.removeRule(rule); err != nil {
+			return added, removed, fmt.Errorf("failed to remove rule %v: %w", rule.RemoveArgs(), err)
+		}
+		removed = append(removed, rule)
+	}
+
+	log.Info().Msgf("Replacing NAT/Firewall rules... done, %d added, %d removed", len(added), len(removed))
+	return added, removed, nil
+}
+
+func containsRule(rules []iptables.Rule, rule iptables.Rule) bool {
+	for _, r := range rules {
+		if r.Equals(rule) {
+			return true
+		}
+	}
+	return false
 }
 
 // Enable enables NAT service.
@@ -100,14 +509,20 @@ func (svc *serviceIPTables) Enable() error {
 		return nil
 	}
 
+	svc.mu.Lock()
 	err := svc.prepare()
+	svc.mu.Unlock()
 	if err != nil {
-		log.Warn().Err(err).Msg("Failed to prepare iptables setup")
+		svc.errs.add(err)
+		svc.throttle.warn(err, "Failed to prepare iptables setup")
 	}
 
+	svc.startReconcile()
+
 	err = svc.ipForward.Enable()
 	if err != nil {
-		log.Warn().Err(err).Msg("Failed to enable IP forwarding")
+		svc.errs.add(err)
+		svc.throttle.warn(err, "Failed to enable IP forwarding")
 	}
 	return err
 }
@@ -119,24 +534,301 @@ func (svc *serviceIPTables) Disable() error {
 		return nil
 	}
 
+	// Report errors suppressed until now, as the service may not log again.
+	defer svc.throttle.flush()
+
+	svc.stopReconcile()
+	svc.stopSweep()
 	svc.ipForward.Disable()
+	removed := append([]iptables.Rule{}, svc.rules...)
 	err := svc.Del(untypedIptRules(svc.rules))
 	if err != nil {
 		return fmt.Errorf("failed to cleanup iptables rules")
 	}
 
+	svc.mu.Lock()
+	svc.protection = nil
+	svc.swept = nil
+	svc.mu.Unlock()
+
 	err = svc.clean()
 	if err != nil {
+		svc.errs.add(err)
 		return fmt.Errorf("failed to cleanup iptables chains")
 	}
 
+	if svc.verifyTeardown {
+		if err := svc.verifyRulesRemoved(removed); err != nil {
+			svc.errs.add(err)
+			return err
+		}
+	}
+
 	return nil
 }
 
+// verifyRulesRemoved checks that none of the given rules is still present, e.g. because it was duplicated.
+func (svc *serviceIPTables) verifyRulesRemoved(rules []iptables.Rule) error {
+	errs := utils.ErrorCollection{}
+	for _, rule := range rules {
+		if err := iptablesExec(rule.CheckArgs()...); err == nil {
+			err := fmt.Errorf("rule is still present after removal: %v", rule.ApplyArgs())
+			svc.throttle.warn(err, "NAT/Firewall rule verification failed")
+			errs.Add(err)
+		}
+	}
+	return errs.Error()
+}
+
+// Diagnostics returns NAT service state snapshot.
+func (svc *serviceIPTables) Diagnostics() NATDiagnostics {
+	svc.mu.Lock()
+	rules := make([]string, len(svc.rules))
+	for i, rule := range svc.rules {
+		rules[i] = strings.Join(rule.ApplyArgs(), " ")
+	}
+	svc.mu.Unlock()
+
+	diag := NATDiagnostics{
+		Backend:           "iptables",
+		BackendPath:       iptablesPath,
+		Rules:             rules,
+		ProtectedNetworks: protectedNetworkStrings(),
+		LastErrors:        svc.errs.list(),
+	}
+	// iptables and sysctl are not used in usermode, so there is nothing to run them for.
+	if !config.GetBool(config.FlagUserMode) && !config.GetBool(config.FlagUserspace) {
+		diag.BackendVersion = svc.detectBackendVersion()
+		diag.IPForward = svc.ipForward.Enabled()
+	}
+	return diag
+}
+
+// detectBackendVersion returns the iptables version, running it only once.
+func (svc *serviceIPTables) detectBackendVersion() string {
+	svc.backendVersionOnce.Do(func() {
+		if svc.backendVersion == "" {
+			svc.backendVersion = backendVersion(svc.ipForward.CommandFactory, iptablesPath)
+		}
+	})
+	return svc.backendVersion
+}
+
+// RuleStats returns packet and byte counters of the given forwarding rule.
+// Only rules tagged with a comment can be looked up.
+func (svc *serviceIPTables) RuleStats(r interface{}) (packets, bytes uint64, err error) {
+	rule, ok := r.(iptables.Rule)
+	if !ok {
+		return 0, 0, fmt.Errorf("not an iptables rule: %v", r)
+	}
+	comment := rule.Comment()
+	if comment == "" {
+		return 0, 0, fmt.Errorf("rule is not tagged with a comment: %v", rule.ApplyArgs())
+	}
+
+	lines, err := iptables.Exec("-S", rule.Chain(), "-v", "--table", rule.Table())
+	if err != nil {
+		return 0, 0, err
+	}
+	return iptables.RuleCounters(lines, comment)
+}
+
+// FlushOwned deletes all kernel rules tagged as set up by the node, including the ones
+// which are not tracked anymore, e.g. after a crash. The MYST chain is deleted once empty.
+func (svc *serviceIPTables) FlushOwned() error {
+	flushed, err := svc.flushOwned()
+	svc.notify(RuleRemoved, flushed)
+	return err
+}
+
+func (svc *serviceIPTables) flushOwned() (flushed []iptables.Rule, err error) {
+	log.Info().Msg("Flushing NAT/Firewall rules owned by the node")
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	errs := utils.ErrorCollection{}
+	flushedComments := make(map[string]bool)
+	for _, owned := range ownedChains {
+		lines, err := iptables.Exec("-S", owned.chain, "--table", owned.table)
+		if err != nil {
+			if owned.chain == chainMyst {
+				// The chain does not exist, so there is nothing to flush.
+				log.Debug().Err(err).Msg("Failed to list MYST iptables chain")
+				continue
+			}
+			errs.Add(err)
+			continue
+		}
+		for _, rule := range iptables.RulesWithCommentPrefix(lines, owned.table, ownedCommentPrefix) {
+			log.Trace().Msgf("Deleting rule: %v", rule)
+			if err := iptablesExec(rule.RemoveArgs()...); err != nil {
+				errs.Add(err)
+				continue
+			}
+			flushed = append(flushed, rule)
+			flushedComments[rule.Comment()] = true
+		}
+	}
+	if err := deleteChainIfEmpty(chainMyst, "nat"); err != nil {
+		errs.Add(err)
+	}
+
+	err = errs.Error()
+	// Forget flushed rules, so Del or the expiry sweep does not act on them.
+	// If nothing failed, none of the owned rules is left in the kernel.
+	var rules, protection []iptables.Rule
+	for _, rule := range svc.rules {
+		comment := rule.Comment()
+		if flushedComments[comment] || (err == nil && strings.HasPrefix(comment, ownedCommentPrefix)) {
+			delete(svc.expiries, ruleID(rule))
+			continue
+		}
+		rules = append(rules, rule)
+		if containsRule(svc.protection, rule) {
+			protection = append(protection, rule)
+		}
+	}
+	svc.rules, svc.protection = rules, protection
+	svc.errs.add(err)
+	log.Info().Err(err).Msg("Flushing NAT/Firewall rules owned by the node... done")
+	return flushed, err
+}
+
+// Diff compares tracked rules with the rules present in the kernel. Missing rules are
+// tracked, but absent in the kernel. Extra rules are tagged as owned by the node in
+// the kernel, but not tracked.
+func (svc *serviceIPTables) Diff() (missing, extra []interface{}, err error) {
+	missingRules, extraRules, err := svc.diff()
+	return untypedIptRules(missingRules), untypedIptRules(extraRules), err
+}
+
+func (svc *serviceIPTables) diff() (missing, extra []iptables.Rule, err error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	listed := make(map[string]struct{})
+	for _, owned := range ownedChains {
+		lines, err := iptables.Exec("-S", owned.chain, "--table", owned.table)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, rule := range iptables.RulesWithCommentPrefix(lines, owned.table, ownedCommentPrefix) {
+			listed[rule.Comment()] = struct{}{}
+			if !svc.tracksComment(rule.Comment()) {
+				extra = append(extra, rule)
+			}
+		}
+	}
+
+	for _, rule := range svc.rules {
+		if comment := rule.Comment(); strings.HasPrefix(comment, ownedCommentPrefix) {
+			if _, ok := listed[comment]; !ok {
+				missing = append(missing, rule)
+			}
+			continue
+		}
+		// Untagged rules can only be checked one by one.
+		if err := iptablesExec(rule.CheckArgs()...); err != nil {
+			missing = append(missing, rule)
+		}
+	}
+	return missing, extra, nil
+}
+
+func (svc *serviceIPTables) tracksComment(comment string) bool {
+	for _, rule := range svc.rules {
+		if rule.Comment() == comment {
+			return true
+		}
+	}
+	return false
+}
+
+func (svc *serviceIPTables) startReconcile() {
+	if svc.reconcileInterval <= 0 {
+		return
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if svc.reconcileStop != nil {
+		return
+	}
+	svc.reconcileStop = make(chan struct{})
+	svc.reconcileDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(svc.reconcileInterval):
+				svc.reconcile()
+			}
+		}
+	}(svc.reconcileStop, svc.reconcileDone)
+}
+
+func (svc *serviceIPTables) stopReconcile() {
+	svc.mu.Lock()
+	stop, done := svc.reconcileStop, svc.reconcileDone
+	svc.reconcileStop, svc.reconcileDone = nil, nil
+	svc.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// reconcile restores tracked rules which were removed by other tools or a firewall reload.
+func (svc *serviceIPTables) reconcile() {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if len(svc.rules) == 0 {
+		return
+	}
+
+	if err := iptablesExec("--list", chainMyst, "--table", "nat"); err != nil {
+		if err := iptablesExec("--new", chainMyst, "--table", "nat"); err != nil {
+			svc.errs.add(err)
+			svc.throttle.warn(err, "Failed to restore MYST iptables chain")
+			return
+		}
+		log.Info().Msg("Restored missing MYST iptables chain")
+		svc.publishReconciled(chainMyst, event.ActionRestored, "chain is missing")
+	}
+
+	for _, rule := range svc.rules {
+		if err := iptablesExec(rule.CheckArgs()...); err == nil {
+			continue
+		}
+
+		if err := iptablesExec(rule.ApplyArgs()...); err != nil {
+			svc.errs.add(err)
+			svc.throttle.warn(err, fmt.Sprintf("Failed to restore missing NAT/Firewall rule: %v", rule.ApplyArgs()))
+			continue
+		}
+		log.Info().Msgf("Restored missing NAT/Firewall rule: %v", rule.ApplyArgs())
+		svc.publishReconciled(ruleID(rule), event.ActionRestored, "rule is missing")
+	}
+}
+
+func (svc *serviceIPTables) publishReconciled(rule, action, reason string) {
+	if svc.publisher == nil {
+		return
+	}
+	svc.publisher.Publish(event.AppTopicRuleReconciled, event.RuleReconciled{Rule: rule, Action: action, Reason: reason})
+}
+
 func (svc *serviceIPTables) applyRule(rule iptables.Rule) error {
 	if err := iptablesExec(rule.ApplyArgs()...); err != nil {
 		return err
 	}
+	delete(svc.swept, ruleID(rule))
 	svc.rules = append(svc.rules, rule)
 	return nil
 }
@@ -145,6 +837,7 @@ func (svc *serviceIPTables) removeRule(rule iptables.Rule) error {
 	if err := iptablesExec(rule.RemoveArgs()...); err != nil {
 		return err
 	}
+	delete(svc.expiries, ruleID(rule))
 	for i := range svc.rules {
 		if svc.rules[i].Equals(rule) {
 			svc.rules = append(svc.rules[:i], svc.rules[i+1:]...)
@@ -160,18 +853,38 @@ func (svc *serviceIPTables) prepare() error {
 		return fmt.Errorf("failed to create MYST iptables chain: %w", err)
 	}
 
-	for _, ipNet := range protectedNetworks() {
+	for _, ipNet := range protectedNetworksV4() {
 		// Protect private networks rule
-		err = svc.applyRule(iptables.AppendTo(chainMyst).RuleSpec(
-			"--destination", ipNet.String(), "--jump", "DNAT", "--to-destination", "240.0.0.1", "--table", "nat"))
-		if err != nil {
+		rule := iptables.AppendTo(chainMyst).RuleSpec(
+			"--destination", ipNet.String(), "--jump", "DNAT", "--to-destination", "240.0.0.1", "--table", "nat").
+			WithComment(ownedCommentPrefix + "protect:" + ipNet.String())
+		if err := svc.applyRule(rule); err != nil {
 			return fmt.Errorf("failed to create blackhole rule in the MYST iptables chain: %w", err)
 		}
+		svc.protection = append(svc.protection, rule)
 	}
 
 	return nil
 }
 
+// deleteChainIfEmpty deletes the chain if it exists and holds no rules.
+func deleteChainIfEmpty(chain, table string) error {
+	lines, err := iptables.Exec("-S", chain, "--table", table)
+	if err != nil {
+		// The chain does not exist.
+		return nil
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "-A ") {
+			return nil
+		}
+	}
+	if err := iptablesExec("--delete-chain", chain, "--table", table); err != nil {
+		return fmt.Errorf("failed to delete %s iptables chain: %w", chain, err)
+	}
+	return nil
+}
+
 func (svc *serviceIPTables) clean() error {
 	err := iptablesExec("--flush", chainMyst, "--table", "nat")
 	if err != nil {
@@ -190,7 +903,8 @@ func makeIPTablesRules(opts Options) (rules []iptables.Rule) {
 	vpnNetwork := opts.VPNNetwork.String()
 
 	rule := iptables.InsertAt(chainPreRouting, 1).RuleSpec(
-		"--source", vpnNetwork, "--jump", chainMyst, "--table", "nat")
+		"--source", vpnNetwork, "--jump", chainMyst, "--table", "nat").
+		WithComment(ownedCommentPrefix + "prerouting:" + vpnNetwork)
 	rules = append(rules, rule)
 
 	// DNS port redirect rule (udp)
@@ -199,7 +913,7 @@ func makeIPTablesRules(opts Options) (rules []iptables.Rule) {
 		"--jump", "REDIRECT",
 		"--to-ports", strconv.Itoa(config.GetInt(config.FlagDNSListenPort)),
 		"--table", "nat",
-	)
+	).WithComment(ownedCommentPrefix + "dns-udp:" + vpnNetwork)
 	rules = append(rules, rule)
 
 	// DNS port redirect rule (tcp)
@@ -208,24 +922,31 @@ func makeIPTablesRules(opts Options) (rules []iptables.Rule) {
 		"--jump", "REDIRECT",
 		"--to-ports", strconv.Itoa(config.GetInt(config.FlagDNSListenPort)),
 		"--table", "nat",
-	)
+	).WithComment(ownedCommentPrefix + "dns-tcp:" + vpnNetwork)
 	rules = append(rules, rule)
 
 	// NAT forwarding rule
-	rule = iptables.AppendTo(chainPostRouting).RuleSpec("--source", vpnNetwork, "!", "--destination", vpnNetwork,
-		"--jump", "SNAT", "--to", opts.ProviderExtIP.String(),
-		"--table", "nat")
+	snatSpec := []string{"--source", vpnNetwork, "!", "--destination", vpnNetwork}
+	if opts.OutInterface != "" {
+		snatSpec = append(snatSpec, "--out-interface", opts.OutInterface)
+	}
+	snatSpec = append(snatSpec, "--jump", "SNAT", "--to", opts.ProviderExtIP.String(), "--table", "nat")
+	rule = iptables.AppendTo(chainPostRouting).RuleSpec(snatSpec...).WithComment(ownedCommentPrefix + "snat:" + vpnNetwork)
 	rules = append(rules, rule)
 
 	// ACCEPT forwarding rules
-	rules = append(rules, iptables.AppendTo(chainForward).RuleSpec("--source", vpnNetwork, "--jump", "ACCEPT"))
-	rules = append(rules, iptables.AppendTo(chainForward).RuleSpec("--destination", vpnNetwork, "--jump", "ACCEPT"))
+	rules = append(rules, iptables.AppendTo(chainForward).RuleSpec("--source", vpnNetwork, "--jump", "ACCEPT").
+		WithComment(ownedCommentPrefix+"forward-out:"+vpnNetwork))
+	rules = append(rules, iptables.AppendTo(chainForward).RuleSpec("--destination", vpnNetwork, "--jump", "ACCEPT").
+		WithComment(ownedCommentPrefix+"forward-in:"+vpnNetwork))
 
 	return rules
 }
 
-func iptablesExec(args ...string) error {
-	args = append([]string{"/usr/sbin/iptables"}, args...)
+const iptablesPath = "/usr/sbin/iptables"
+
+var iptablesExec = func(args ...string) error {
+	args = append([]string{iptablesPath}, args...)
 	if err := cmdutil.SudoExec(args...); err != nil {
 		return errors.Wrap(err, "error calling IPTables")
 	}
@@ -0,0 +1,831 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package nat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mysteriumnetwork/node/config"
+	"github.com/mysteriumnetwork/node/firewall/iptables"
+	"github.com/mysteriumnetwork/node/nat/event"
+)
+
+var _ RuleManager = &serviceIPTables{}
+
+func Test_ServiceIPTables_Diagnostics(t *testing.T) {
+	mf := &mockCommandFactory{
+		MockCommand: &mockCommand{OutputRes: []byte("1")},
+	}
+	svc := &serviceIPTables{
+		ipForward: serviceIPForward{
+			CommandFactory: mf.Create,
+			CommandRead:    []string{"doesnt", "matter"},
+		},
+		rules: []iptables.Rule{
+			iptables.AppendTo(chainForward).RuleSpec("--source", "10.8.0.0/24", "--jump", "ACCEPT"),
+		},
+		backendVersion: "iptables v1.8.7 (nf_tables)",
+	}
+	svc.errs.add(errors.New("failed to apply"))
+
+	diag := svc.Diagnostics()
+
+	assert.Equal(t, "iptables", diag.Backend)
+	assert.Equal(t, "/usr/sbin/iptables", diag.BackendPath)
+	assert.Equal(t, "iptables v1.8.7 (nf_tables)", diag.BackendVersion)
+	assert.True(t, diag.IPForward)
+	assert.Equal(t, []string{"-A FORWARD --source 10.8.0.0/24 --jump ACCEPT"}, diag.Rules)
+	assert.Len(t, diag.LastErrors, 1)
+	assert.Contains(t, diag.LastErrors[0], "failed to apply")
+	assert.Len(t, svc.rules, 1)
+}
+
+func Test_ServiceIPTables_DiagnosticsDetectsBackendVersionOnce(t *testing.T) {
+	var versionCalls int
+	mc := &mockCommand{OutputRes: []byte("1"), CombinedOutputRes: []byte("iptables v1.8.4 (legacy)\n")}
+	svc := &serviceIPTables{
+		ipForward: serviceIPForward{
+			CommandFactory: func(name string, arg ...string) Command {
+				if name == iptablesPath {
+					versionCalls++
+				}
+				return mc
+			},
+			CommandRead: []string{"doesnt", "matter"},
+		},
+	}
+
+	assert.Equal(t, "iptables v1.8.4 (legacy)", svc.Diagnostics().BackendVersion)
+	assert.Equal(t, "iptables v1.8.4 (legacy)", svc.Diagnostics().BackendVersion)
+	assert.Equal(t, 1, versionCalls)
+}
+
+func Test_ServiceIPTables_DiagnosticsInUsermode(t *testing.T) {
+	config.Current.SetUser(config.FlagUserMode.Name, true)
+	defer config.Current.RemoveUser(config.FlagUserMode.Name)
+	svc := &serviceIPTables{
+		ipForward: serviceIPForward{
+			CommandFactory: func(name string, arg ...string) Command {
+				t.Fatalf("no commands should be run in usermode, got %s %v", name, arg)
+				return nil
+			},
+		},
+	}
+
+	diag := svc.Diagnostics()
+
+	assert.Equal(t, "iptables", diag.Backend)
+	assert.Empty(t, diag.BackendVersion)
+	assert.False(t, diag.IPForward)
+}
+
+func Test_BackendVersion(t *testing.T) {
+	mc := &mockCommand{CombinedOutputRes: []byte("iptables v1.8.4 (legacy)\n")}
+	mf := &mockCommandFactory{MockCommand: mc}
+	assert.Equal(t, "iptables v1.8.4 (legacy)", backendVersion(mf.Create, iptablesPath))
+
+	mc.CombinedOutputError = errors.New("executable file not found")
+	assert.Empty(t, backendVersion(mf.Create, iptablesPath))
+}
+
+func Test_Options_normalize(t *testing.T) {
+	_, vpnNetwork, _ := net.ParseCIDR("10.0.0.0/24")
+	vpnNetwork.IP = net.ParseIP("10.0.0.5").To4()
+
+	opts, err := Options{VPNNetwork: *vpnNetwork}.normalize()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/24", opts.VPNNetwork.String())
+
+	rules := makeIPTablesRules(opts)
+	assert.Equal(t, []string{
+		"-I", "PREROUTING", "1", "--source", "10.0.0.0/24", "--jump", "MYST", "--table", "nat",
+		"-m", "comment", "--comment", "myst:prerouting:10.0.0.0/24",
+	}, rules[0].ApplyArgs())
+
+	_, err = Options{}.normalize()
+	assert.Error(t, err)
+
+	_, err = Options{VPNNetwork: *vpnNetwork, OutInterface: "no-such-iface0"}.normalize()
+	assert.Error(t, err)
+
+	_, err = Options{VPNNetwork: net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.IPMask{255, 0, 255, 0}}}.normalize()
+	assert.Error(t, err)
+}
+
+func Test_MakeIPTablesRules_OutInterface(t *testing.T) {
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")}
+
+	opts.OutInterface = loopbackInterface(t)
+	opts, err := opts.normalize()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"-A", "POSTROUTING", "--source", "10.8.0.0/24", "!", "--destination", "10.8.0.0/24", "--out-interface", opts.OutInterface,
+		"--jump", "SNAT", "--to", "1.2.3.4", "--table", "nat", "-m", "comment", "--comment", "myst:snat:10.8.0.0/24",
+	}, makeIPTablesRules(opts)[3].ApplyArgs())
+
+	opts.OutInterface = ""
+	assert.NotContains(t, makeIPTablesRules(opts)[3].ApplyArgs(), "--out-interface")
+}
+
+// loopbackInterface returns the name of the loopback interface, which differs between platforms.
+func loopbackInterface(t *testing.T) string {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface found")
+	return ""
+}
+
+func Test_ServiceIPTables_ReconcileRestoresMissingRules(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	svc := &serviceIPTables{reconcileInterval: time.Millisecond}
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	assert.NoError(t, err)
+	assert.Len(t, ipt.kernelRules(), len(rules))
+
+	dropped := typedIptRules(rules)[0]
+	assert.NoError(t, ipt.exec(dropped.RemoveArgs()...))
+	assert.Len(t, ipt.kernelRules(), len(rules)-1)
+
+	svc.startReconcile()
+	defer svc.stopReconcile()
+
+	assert.Eventually(t, func() bool {
+		return len(ipt.kernelRules()) == len(rules)
+	}, time.Second, 5*time.Millisecond)
+	assert.Contains(t, ipt.kernelRules(), ruleKey(dropped.CheckArgs()[1:]))
+}
+
+func Test_ServiceIPTables_ReconcilePublishesRestoredRules(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	publisher := &recordingPublisher{}
+	svc := &serviceIPTables{publisher: publisher}
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	require.NoError(t, err)
+
+	svc.reconcile()
+	assert.Empty(t, publisher.events)
+
+	dropped := typedIptRules(rules)[3]
+	assert.NoError(t, ipt.exec(dropped.RemoveArgs()...))
+	svc.reconcile()
+
+	assert.Equal(t, []interface{}{event.RuleReconciled{
+		Rule:   strings.Join(dropped.ApplyArgs(), " "),
+		Action: event.ActionRestored,
+		Reason: "rule is missing",
+	}}, publisher.events)
+	assert.Equal(t, []string{event.AppTopicRuleReconciled}, publisher.topics)
+}
+
+func Test_ServiceIPTables_RemovesExpiredRules(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	publisher := &recordingPublisher{}
+	svc := &serviceIPTables{publisher: publisher, sweepInterval: 5 * time.Millisecond}
+	defer svc.stopSweep()
+
+	_, permanentNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	permanent, err := svc.Setup(Options{VPNNetwork: *permanentNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	require.NoError(t, err)
+	_, sessionNetwork, _ := net.ParseCIDR("10.9.0.0/24")
+	session, err := svc.Setup(Options{VPNNetwork: *sessionNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.9.0.1"), TTL: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Len(t, ipt.kernelRules(), len(permanent)+len(session))
+
+	assert.Eventually(t, func() bool {
+		return len(ipt.kernelRules()) == len(permanent)
+	}, time.Second, 5*time.Millisecond)
+
+	for _, rule := range typedIptRules(permanent) {
+		assert.Contains(t, ipt.kernelRules(), ruleKey(rule.CheckArgs()[1:]))
+	}
+	svc.mu.Lock()
+	assert.ElementsMatch(t, typedIptRules(permanent), svc.rules)
+	svc.mu.Unlock()
+
+	var expired []interface{}
+	for _, rule := range typedIptRules(session) {
+		expired = append(expired, event.RuleExpired{Rule: strings.Join(rule.ApplyArgs(), " "), TTL: 50 * time.Millisecond})
+	}
+	assert.Eventually(t, func() bool {
+		_, events := publisher.published()
+		return len(events) == len(expired)
+	}, time.Second, 5*time.Millisecond)
+	topics, events := publisher.published()
+	assert.ElementsMatch(t, expired, events)
+	for _, topic := range topics {
+		assert.Equal(t, event.AppTopicRuleExpired, topic)
+	}
+}
+
+func Test_ServiceIPTables_DelSucceedsForExpiredRules(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	// Like iptables, fail removing rules which are not present.
+	iptablesExec = func(args ...string) error {
+		if args[0] == "-D" {
+			if err := ipt.exec(append([]string{"-C"}, args[1:]...)...); err != nil {
+				return err
+			}
+		}
+		return ipt.exec(args...)
+	}
+	svc := &serviceIPTables{sweepInterval: 5 * time.Millisecond}
+	defer svc.stopSweep()
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.9.0.0/24")
+	rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.9.0.1"), TTL: 20 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(ipt.kernelRules()) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	assert.NoError(t, svc.Del(rules))
+	assert.Error(t, svc.Del(rules), "rules are deleted only once")
+}
+
+func Test_ServiceIPTables_SweepNotStarted(t *testing.T) {
+	_, vpnNetwork, _ := net.ParseCIDR("10.9.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.9.0.1"), TTL: time.Minute}
+
+	t.Run("setup fails", func(t *testing.T) {
+		ipt := mockIPTablesExec(t)
+		iptablesExec = func(args ...string) error {
+			if args[0] == "-A" && args[1] == chainForward {
+				return errors.New("forwarding rule failed")
+			}
+			return ipt.exec(args...)
+		}
+		svc := &serviceIPTables{}
+
+		_, err := svc.Setup(opts)
+
+		assert.Error(t, err)
+		assert.Nil(t, svc.sweepStop)
+	})
+	t.Run("usermode", func(t *testing.T) {
+		mockIPTablesExec(t)
+		config.Current.SetUser(config.FlagUserMode.Name, true)
+		defer config.Current.RemoveUser(config.FlagUserMode.Name)
+		svc := &serviceIPTables{}
+
+		_, err := svc.Setup(opts)
+
+		assert.NoError(t, err)
+		assert.Nil(t, svc.sweepStop)
+	})
+	t.Run("stopped on disable", func(t *testing.T) {
+		mockIPTablesExec(t)
+		svc := &serviceIPTables{ipForward: serviceIPForward{forward: true}}
+
+		_, err := svc.Setup(opts)
+		require.NoError(t, err)
+		require.NotNil(t, svc.sweepStop)
+		done := svc.sweepDone
+
+		require.NoError(t, svc.Disable())
+		assert.Nil(t, svc.sweepStop)
+		select {
+		case <-done:
+		default:
+			t.Fatal("sweep is still running")
+		}
+	})
+}
+
+func Test_ServiceIPTables_SetupRollsBackPartiallyAppliedRules(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	svc := &serviceIPTables{}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")}
+	rules := makeIPTablesRules(opts)
+	// Forwarding rule fails after SNAT rule was applied.
+	failing := rules[4]
+	iptablesExec = func(args ...string) error {
+		if ruleKey(args) == ruleKey(failing.ApplyArgs()) {
+			return errors.New("iptables: Resource temporarily unavailable")
+		}
+		return ipt.exec(args...)
+	}
+
+	_, err := svc.Setup(opts)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprint(failing.ApplyArgs()))
+	assert.Contains(t, ipt.calls, strings.Join(rules[3].RemoveArgs(), " "))
+	assert.Empty(t, ipt.kernelRules())
+	assert.Empty(t, svc.rules)
+}
+
+func Test_ServiceIPTables_Validate(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	config.Current.SetUser(config.FlagFirewallProtectedNetworks.Name, "10.9.1.0/24")
+	defer config.Current.RemoveUser(config.FlagFirewallProtectedNetworks.Name)
+
+	svc := &serviceIPTables{}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("127.0.0.1"), DNSIP: net.ParseIP("10.8.0.1")}
+
+	assert.NoError(t, svc.Validate(opts))
+
+	invalid := opts
+	invalid.VPNNetwork = net.IPNet{}
+	assert.ErrorContains(t, svc.Validate(invalid), "invalid VPN network")
+
+	notLocal := opts
+	notLocal.ProviderExtIP = net.ParseIP("192.0.2.1")
+	assert.ErrorIs(t, svc.Validate(notLocal), ErrNATTargetNotLocal)
+
+	protected := opts
+	_, wide, _ := net.ParseCIDR("10.9.0.0/16")
+	protected.VPNNetwork = *wide
+	assert.ErrorIs(t, svc.Validate(protected), ErrNATProtectedNetwork)
+
+	assert.Empty(t, ipt.calls)
+	assert.Empty(t, svc.rules)
+
+	_, err := svc.Setup(opts)
+	require.NoError(t, err)
+	assert.ErrorIs(t, svc.Validate(opts), ErrNATRuleConflict)
+}
+
+func Test_ServiceIPTables_SetupRefusesRulesOverLimit(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	opts := func(cidr string) Options {
+		_, vpnNetwork, _ := net.ParseCIDR(cidr)
+		return Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: vpnNetwork.IP}
+	}
+	perSetup := len(makeIPTablesRules(opts("10.8.0.0/24")))
+	svc := &serviceIPTables{maxRules: 2 * perSetup}
+
+	_, err := svc.Setup(opts("10.8.0.0/24"))
+	require.NoError(t, err)
+	_, err = svc.Setup(opts("10.9.0.0/24"))
+	require.NoError(t, err)
+	calls := len(ipt.calls)
+
+	_, err = svc.Setup(opts("10.10.0.0/24"))
+
+	assert.ErrorIs(t, err, ErrRuleLimitExceeded)
+	assert.Len(t, ipt.calls, calls, "iptables must not be called")
+	assert.Len(t, svc.rules, 2*perSetup)
+	assert.Len(t, ipt.kernelRules(), 2*perSetup)
+}
+
+func Test_ServiceIPTables_RuleLimitIgnoresProtectedNetworks(t *testing.T) {
+	mockIPTablesExec(t)
+	defer config.Current.RemoveUser(config.FlagFirewallProtectedNetworks.Name)
+	config.Current.SetUser(config.FlagFirewallProtectedNetworks.Name, "10.0.0.0/8,192.168.0.0/16")
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")}
+	svc := &serviceIPTables{maxRules: len(makeIPTablesRules(opts))}
+	svc.mu.Lock()
+	require.NoError(t, svc.prepare())
+	svc.mu.Unlock()
+
+	_, err := svc.Setup(opts)
+	require.NoError(t, err)
+	assert.NoError(t, svc.ReplaceAll(untypedIptRules(svc.rules)))
+
+	opts.DNSIP = net.ParseIP("10.8.0.2")
+	_, err = svc.Setup(opts)
+	assert.ErrorIs(t, err, ErrRuleLimitExceeded)
+}
+
+func Test_ServiceIPTables_ReplaceAll(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	svc := &serviceIPTables{}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")}
+	_, err := svc.Setup(opts)
+	require.NoError(t, err)
+	before := makeIPTablesRules(opts)
+
+	opts.ProviderExtIP = net.ParseIP("5.6.7.8")
+	after := makeIPTablesRules(opts)
+	ipt.calls = nil
+
+	err = svc.ReplaceAll(untypedIptRules(after))
+
+	assert.NoError(t, err)
+	// Only the SNAT rule differs between the two sets.
+	assert.Equal(t, []string{
+		strings.Join(after[3].ApplyArgs(), " "),
+		strings.Join(before[3].RemoveArgs(), " "),
+	}, ipt.calls)
+	assert.Len(t, ipt.kernelRules(), len(after))
+	assert.Len(t, svc.rules, len(after))
+	for _, rule := range after {
+		assert.True(t, containsRule(svc.rules, rule))
+	}
+}
+
+func Test_ServiceIPTables_ReplaceAllKeepsProtectedNetworks(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	defer config.Current.RemoveUser(config.FlagFirewallProtectedNetworks.Name)
+	config.Current.SetUser(config.FlagFirewallProtectedNetworks.Name, "10.0.0.0/8,192.168.0.0/16")
+	svc := &serviceIPTables{
+		ipForward: serviceIPForward{
+			CommandFactory: (&mockCommandFactory{MockCommand: &mockCommand{OutputRes: []byte("1")}}).Create,
+			CommandRead:    []string{"doesnt", "matter"},
+		},
+	}
+	require.NoError(t, svc.Enable())
+	protection := []string{
+		"MYST --destination 10.0.0.0/8 --jump DNAT --to-destination 240.0.0.1 --table nat -m comment --comment myst:protect:10.0.0.0/8",
+		"MYST --destination 192.168.0.0/16 --jump DNAT --to-destination 240.0.0.1 --table nat -m comment --comment myst:protect:192.168.0.0/16",
+	}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")}
+	_, err := svc.Setup(opts)
+	require.NoError(t, err)
+
+	opts.ProviderExtIP = net.ParseIP("5.6.7.8")
+	after := makeIPTablesRules(opts)
+	err = svc.ReplaceAll(untypedIptRules(after))
+
+	assert.NoError(t, err)
+	assert.Subset(t, ipt.kernelRules(), protection)
+	assert.Len(t, ipt.kernelRules(), len(after)+len(protection))
+	assert.Len(t, svc.rules, len(after)+len(protection))
+}
+
+func Test_ServiceIPTables_ReplaceAllRollsBack(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	svc := &serviceIPTables{}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")}
+	_, err := svc.Setup(opts)
+	require.NoError(t, err)
+	kernelBefore := ipt.kernelRules()
+
+	_, vpnNetwork, _ = net.ParseCIDR("10.9.0.0/24")
+	opts.VPNNetwork = *vpnNetwork
+	after := makeIPTablesRules(opts)
+	failing := after[4]
+	iptablesExec = func(args ...string) error {
+		if ruleKey(args) == ruleKey(failing.ApplyArgs()) {
+			return errors.New("iptables: Resource temporarily unavailable")
+		}
+		return ipt.exec(args...)
+	}
+
+	err = svc.ReplaceAll(untypedIptRules(after))
+
+	assert.ErrorContains(t, err, fmt.Sprint(failing.ApplyArgs()))
+	assert.ElementsMatch(t, kernelBefore, ipt.kernelRules())
+	assert.Len(t, svc.rules, len(kernelBefore))
+}
+
+func Test_ServiceIPTables_NotifiesRuleObserver(t *testing.T) {
+	mockIPTablesExec(t)
+	svc := &serviceIPTables{ipForward: serviceIPForward{forward: true}}
+	type notification struct {
+		action RuleAction
+		rules  []interface{}
+	}
+	var notified []notification
+	svc.SetRuleObserver(func(action RuleAction, rules []interface{}) {
+		notified = append(notified, notification{action, rules})
+	})
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Del(rules[:1]))
+	assert.NoError(t, svc.Disable())
+
+	assert.Equal(t, []notification{
+		{RuleAdded, rules},
+		{RuleRemoved, rules[:1]},
+		{RuleRemoved, rules[1:]},
+	}, notified)
+}
+
+func Test_ServiceIPTables_SetupResolvesProviderExtIP(t *testing.T) {
+	mockIPTablesExec(t)
+	var resolved int
+	svc := &serviceIPTables{resolveOutboundIP: func() (net.IP, error) {
+		resolved++
+		return net.ParseIP("192.168.1.10"), nil
+	}}
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	for i := 0; i < 2; i++ {
+		rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, DNSIP: net.ParseIP("10.8.0.1")})
+		assert.NoError(t, err)
+		snat := typedIptRules(rules)[3]
+		assert.Contains(t, strings.Join(snat.ApplyArgs(), " "), "--jump SNAT --to 192.168.1.10")
+	}
+	assert.Equal(t, 1, resolved)
+
+	svc.outboundIPResolved = time.Now().Add(-outboundIPCacheTTL)
+	_, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, DNSIP: net.ParseIP("10.8.0.1")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resolved, "stale IP must be resolved again")
+
+	svc = &serviceIPTables{resolveOutboundIP: func() (net.IP, error) {
+		return nil, errors.New("network is unreachable")
+	}}
+	_, err = svc.Setup(Options{VPNNetwork: *vpnNetwork, DNSIP: net.ParseIP("10.8.0.1")})
+	assert.ErrorContains(t, err, "network is unreachable")
+}
+
+func Test_ServiceIPTables_SetOutboundIPResolver(t *testing.T) {
+	mockIPTablesExec(t)
+	svc := &serviceIPTables{resolveOutboundIP: func() (net.IP, error) {
+		return net.ParseIP("192.168.1.10"), nil
+	}}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, DNSIP: net.ParseIP("10.8.0.1")}
+	_, err := svc.Setup(opts)
+	require.NoError(t, err)
+
+	svc.SetOutboundIPResolver(func() (net.IP, error) {
+		return net.ParseIP("192.168.2.20"), nil
+	})
+	_, vpnNetwork, _ = net.ParseCIDR("10.9.0.0/24")
+	rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, DNSIP: net.ParseIP("10.9.0.1")})
+
+	assert.NoError(t, err)
+	snat := typedIptRules(rules)[3]
+	assert.Contains(t, strings.Join(snat.ApplyArgs(), " "), "--jump SNAT --to 192.168.2.20")
+}
+
+func Test_ServiceIPTables_SetupFailureInvalidatesProviderExtIP(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	var resolved int
+	svc := &serviceIPTables{resolveOutboundIP: func() (net.IP, error) {
+		resolved++
+		return net.ParseIP("192.168.1.10"), nil
+	}}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	opts := Options{VPNNetwork: *vpnNetwork, DNSIP: net.ParseIP("10.8.0.1")}
+	iptablesExec = func(args ...string) error {
+		if strings.Contains(strings.Join(args, " "), "SNAT") {
+			return errors.New("iptables: Invalid argument")
+		}
+		return ipt.exec(args...)
+	}
+
+	_, err := svc.Setup(opts)
+	require.Error(t, err)
+	iptablesExec = ipt.exec
+	_, err = svc.Setup(opts)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resolved)
+}
+
+func Test_ServiceIPTables_RuleStats(t *testing.T) {
+	original := iptables.Exec
+	defer func() { iptables.Exec = original }()
+	var calledWith []string
+	iptables.Exec = func(args ...string) ([]string, error) {
+		calledWith = args
+		return []string{
+			"-P FORWARD ACCEPT",
+			`-A FORWARD -s 10.8.0.0/24 -m comment --comment "myst:forward-out:10.8.0.0/24" -c 42 4200 -j ACCEPT`,
+			`-A FORWARD -d 10.8.0.0/24 -m comment --comment "myst:forward-in:10.8.0.0/24" -c 21 2100 -j ACCEPT`,
+		}, nil
+	}
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	rules := makeIPTablesRules(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	svc := &serviceIPTables{}
+
+	packets, bytes, err := svc.RuleStats(rules[len(rules)-1])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-S", "FORWARD", "-v", "--table", "filter"}, calledWith)
+	assert.Equal(t, uint64(21), packets)
+	assert.Equal(t, uint64(2100), bytes)
+
+	_, _, err = svc.RuleStats(rules[0])
+	assert.Error(t, err)
+}
+
+func Test_ServiceIPTables_DisableVerifiesTeardown(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	svc := &serviceIPTables{
+		ipForward:      serviceIPForward{forward: true},
+		verifyTeardown: true,
+	}
+
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	rules, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	assert.NoError(t, err)
+
+	// Rule is duplicated, so a single delete leaves it in place.
+	stuck := typedIptRules(rules)[len(rules)-1]
+	iptablesExec = func(args ...string) error {
+		if args[0] == "-D" && ruleKey(args[1:]) == ruleKey(stuck.RemoveArgs()[1:]) {
+			return nil
+		}
+		return ipt.exec(args...)
+	}
+
+	err = svc.Disable()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), strings.Join(stuck.ApplyArgs(), " "))
+	assert.Contains(t, svc.errs.list()[0], "still present")
+}
+
+func Test_ServiceIPTables_FlushOwned(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	original := iptables.Exec
+	defer func() { iptables.Exec = original }()
+	mystRule := "-A MYST -d 10.8.0.1/32 -p udp -m udp --dport 53 -m comment --comment myst:dns-udp:10.8.0.0/24 -j REDIRECT --to-ports 5353"
+	iptables.Exec = func(args ...string) ([]string, error) {
+		switch args[1] {
+		case chainPreRouting:
+			return []string{
+				"-P PREROUTING ACCEPT",
+				"-A PREROUTING -s 10.8.0.0/24 -m comment --comment myst:prerouting:10.8.0.0/24 -j MYST",
+				"-A PREROUTING -i docker0 -j DOCKER",
+			}, nil
+		case chainMyst:
+			for _, call := range ipt.calls {
+				if strings.HasPrefix(call, "-D MYST") {
+					return []string{"-N MYST"}, nil
+				}
+			}
+			return []string{"-N MYST", mystRule}, nil
+		case chainPostRouting:
+			return []string{
+				"-P POSTROUTING ACCEPT",
+				`-A POSTROUTING -s 10.8.0.0/24 ! -d 10.8.0.0/24 -m comment --comment "myst:snat:10.8.0.0/24" -j SNAT --to-source 1.2.3.4`,
+				"-A POSTROUTING -s 172.17.0.0/16 -j MASQUERADE",
+			}, nil
+		case chainForward:
+			return []string{
+				"-P FORWARD DROP",
+				"-A FORWARD -s 10.8.0.0/24 -m comment --comment myst:forward-out:10.8.0.0/24 -j ACCEPT",
+				"-A FORWARD -o docker0 -m comment --comment docker -j ACCEPT",
+			}, nil
+		}
+		return nil, nil
+	}
+	untagged := iptables.AppendTo(chainForward).RuleSpec("--source", "10.9.0.0/24", "--jump", "ACCEPT")
+	tracked := iptables.AppendTo(chainForward).RuleSpec("--source", "10.8.0.0/24", "--jump", "ACCEPT").WithComment("myst:forward-out:10.8.0.0/24")
+	svc := &serviceIPTables{
+		rules:    []iptables.Rule{untagged, tracked},
+		expiries: map[string]ruleExpiry{ruleID(tracked): {at: time.Now().Add(time.Minute), ttl: time.Minute}},
+	}
+
+	err := svc.FlushOwned()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"-D PREROUTING -s 10.8.0.0/24 -m comment --comment myst:prerouting:10.8.0.0/24 -j MYST --table nat",
+		"-D MYST -d 10.8.0.1/32 -p udp -m udp --dport 53 -m comment --comment myst:dns-udp:10.8.0.0/24 -j REDIRECT --to-ports 5353 --table nat",
+		"-D POSTROUTING -s 10.8.0.0/24 ! -d 10.8.0.0/24 -m comment --comment myst:snat:10.8.0.0/24 -j SNAT --to-source 1.2.3.4 --table nat",
+		"-D FORWARD -s 10.8.0.0/24 -m comment --comment myst:forward-out:10.8.0.0/24 -j ACCEPT --table filter",
+		"--delete-chain MYST --table nat",
+	}, ipt.calls)
+	assert.Equal(t, []iptables.Rule{untagged}, svc.rules)
+	assert.Empty(t, svc.expiries)
+}
+
+func Test_ServiceIPTables_Diff(t *testing.T) {
+	ipt := mockIPTablesExec(t)
+	svc := &serviceIPTables{}
+	_, vpnNetwork, _ := net.ParseCIDR("10.8.0.0/24")
+	_, err := svc.Setup(Options{VPNNetwork: *vpnNetwork, ProviderExtIP: net.ParseIP("1.2.3.4"), DNSIP: net.ParseIP("10.8.0.1")})
+	assert.NoError(t, err)
+	forwardOut, dnsRedirect := svc.rules[4], svc.rules[1]
+	delete(ipt.rules, ruleKey(dnsRedirect.RemoveArgs()[1:]))
+
+	original := iptables.Exec
+	defer func() { iptables.Exec = original }()
+	iptables.Exec = func(args ...string) ([]string, error) {
+		switch args[1] {
+		case chainPreRouting:
+			return []string{
+				"-A PREROUTING -s 10.8.0.0/24 -m comment --comment myst:prerouting:10.8.0.0/24 -j MYST",
+			}, nil
+		case chainMyst:
+			return []string{
+				"-A MYST -d 10.8.0.1/32 -p tcp -m tcp --dport 53 -m comment --comment myst:dns-tcp:10.8.0.0/24 -j REDIRECT --to-ports 0",
+			}, nil
+		case chainPostRouting:
+			return []string{
+				`-A POSTROUTING -s 10.8.0.0/24 ! -d 10.8.0.0/24 -m comment --comment "myst:snat:10.8.0.0/24" -j SNAT --to-source 1.2.3.4`,
+			}, nil
+		case chainForward:
+			return []string{
+				"-A FORWARD -d 10.8.0.0/24 -m comment --comment myst:forward-in:10.8.0.0/24 -j ACCEPT",
+				"-A FORWARD -d 10.9.0.0/24 -m comment --comment myst:forward-in:10.9.0.0/24 -j ACCEPT",
+				"-A FORWARD -o docker0 -m comment --comment docker -j ACCEPT",
+			}, nil
+		}
+		return nil, nil
+	}
+
+	missing, extra, err := svc.diff()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []iptables.Rule{dnsRedirect, forwardOut}, missing)
+	assert.Len(t, extra, 1)
+	assert.Equal(t, "myst:forward-in:10.9.0.0/24", extra[0].Comment())
+}
+
+type iptablesExecMock struct {
+	mu    sync.Mutex
+	rules map[string]struct{}
+	calls []string
+}
+
+func mockIPTablesExec(t *testing.T) *iptablesExecMock {
+	mock := &iptablesExecMock{rules: make(map[string]struct{})}
+	original := iptablesExec
+	iptablesExec = mock.exec
+	t.Cleanup(func() { iptablesExec = original })
+	return mock
+}
+
+func (m *iptablesExecMock) exec(args ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, strings.Join(args, " "))
+	switch args[0] {
+	case "-A":
+		m.rules[ruleKey(args[1:])] = struct{}{}
+	case "-I":
+		m.rules[ruleKey(append([]string{args[1]}, args[3:]...))] = struct{}{}
+	case "-D":
+		delete(m.rules, ruleKey(args[1:]))
+	case "-C":
+		if _, ok := m.rules[ruleKey(args[1:])]; !ok {
+			return errors.New("bad rule (does a matching rule exist in that chain?)")
+		}
+	}
+	return nil
+}
+
+func (m *iptablesExecMock) kernelRules() (rules []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for rule := range m.rules {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func ruleKey(chainAndSpec []string) string {
+	return strings.Join(chainAndSpec, " ")
+}
+
+type recordingPublisher struct {
+	mu     sync.Mutex
+	topics []string
+	events []interface{}
+}
+
+func (p *recordingPublisher) Publish(topic string, data interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.topics = append(p.topics, topic)
+	p.events = append(p.events, data)
+}
+
+func (p *recordingPublisher) published() (topics []string, events []interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string{}, p.topics...), append([]interface{}{}, p.events...)
+}
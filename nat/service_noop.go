@@ -17,7 +17,9 @@
 
 package nat
 
-type serviceNoop struct{}
+type serviceNoop struct {
+	rulesUnsupported
+}
 
 // Setup sets NAT/Firewall rules for the given NATOptions.
 func (svc *serviceNoop) Setup(opts Options) (appliedRules []interface{}, err error) {
@@ -38,3 +40,8 @@ func (svc *serviceNoop) Enable() error {
 func (svc *serviceNoop) Disable() error {
 	return nil
 }
+
+// Diagnostics returns NAT service state snapshot.
+func (svc *serviceNoop) Diagnostics() NATDiagnostics {
+	return NATDiagnostics{Backend: "noop"}
+}
@@ -31,9 +31,12 @@ import (
 )
 
 type servicePFCtl struct {
+	rulesUnsupported
+
 	mu        sync.Mutex
 	rules     []string
 	ipForward serviceIPForward
+	errs      errorLog
 }
 
 // Setup sets NAT/Firewall rules for the given NATOptions.
@@ -42,6 +45,11 @@ func (service *servicePFCtl) Setup(opts Options) (appliedRules []interface{}, er
 	service.mu.Lock()
 	defer service.mu.Unlock()
 
+	opts, err = opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+
 	rules, err := makePfctlRules(opts)
 	if err != nil {
 		return nil, err
@@ -86,9 +94,12 @@ func (service *servicePFCtl) Del(rules []interface{}) error {
 }
 
 func makePfctlRules(opts Options) (rules []string, err error) {
-	externalIface, err := ifaceByAddress(opts.ProviderExtIP)
-	if err != nil {
-		return nil, err
+	externalIface := opts.OutInterface
+	if externalIface == "" {
+		externalIface, err = ifaceByAddress(opts.ProviderExtIP)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// DNS port redirect rule
@@ -105,7 +116,7 @@ func makePfctlRules(opts Options) (rules []string, err error) {
 	rules = append(rules, rule)
 
 	// Protect private networks rule
-	networks := protectedNetworks()
+	networks := protectedNetworksV4()
 	if len(networks) > 0 {
 		var targets []string
 		for _, network := range networks {
@@ -134,6 +145,7 @@ func makePfctlRules(opts Options) (rules []string, err error) {
 func (service *servicePFCtl) Enable() error {
 	err := service.ipForward.Enable()
 	if err != nil {
+		service.errs.add(err)
 		log.Warn().Err(err).Msg("Failed to enable IP forwarding")
 	}
 	return err
@@ -150,6 +162,21 @@ func (service *servicePFCtl) Disable() error {
 	return nil
 }
 
+// Diagnostics returns NAT service state snapshot.
+func (service *servicePFCtl) Diagnostics() NATDiagnostics {
+	service.mu.Lock()
+	rules := append([]string{}, service.rules...)
+	service.mu.Unlock()
+
+	return NATDiagnostics{
+		Backend:           "pfctl",
+		IPForward:         service.ipForward.Enabled(),
+		Rules:             rules,
+		ProtectedNetworks: protectedNetworkStrings(),
+		LastErrors:        service.errs.list(),
+	}
+}
+
 func ifaceByAddress(ip net.IP) (string, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -176,6 +203,7 @@ func (service *servicePFCtl) pfctlExec(rules []string) error {
 
 	if output, err := cmdutil.ExecOutput("sh", "-c", arguments); err != nil {
 		if !strings.Contains(output, natRule) {
+			service.errs.add(err)
 			log.Warn().Err(err).Msgf("Failed to create pfctl rule")
 			return err
 		}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mysteriumnetwork/node/core/port"
+)
+
+type mockClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []mockWaiter
+}
+
+type mockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newMockClock() *mockClock {
+	return &mockClock{now: time.Unix(0, 0)}
+}
+
+func (c *mockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *mockClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, mockWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *mockClock) WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	timeout := c.After(d)
+	go func() {
+		select {
+		case <-timeout:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// Advance moves the clock forward and fires all due waiters.
+func (c *mockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	var pending []mockWaiter
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func TestPinger_PingConsumerPeer_TimeoutWithMockClock(t *testing.T) {
+	clock := newMockClock()
+	pinger := NewPinger(&PingConfig{
+		Interval: time.Millisecond,
+		Timeout:  time.Hour,
+	}, &mockPublisher{}).(*Pinger)
+	pinger.clock = clock
+
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(2)
+	require.NoError(t, err)
+
+	pingErr := make(chan error, 1)
+	go func() {
+		_, err := pinger.PingConsumerPeer(context.Background(), "id", "127.0.0.1", []int{ports[0].Num()}, []int{ports[1].Num()}, 2, 1)
+		pingErr <- err
+	}()
+
+	select {
+	case err := <-pingErr:
+		t.Fatalf("ping returned before timeout: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Hour)
+	select {
+	case err := <-pingErr:
+		assert.ErrorIs(t, err, ErrTooFew)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ping did not time out")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"time"
+
+	"github.com/mysteriumnetwork/node/nat"
+)
+
+type serviceTypeKey struct{}
+
+// WithServiceType returns a context which labels NAT pinging with the
+// service type (e.g. wireguard, openvpn) it is performed for.
+func WithServiceType(ctx context.Context, serviceType string) context.Context {
+	return context.WithValue(ctx, serviceTypeKey{}, serviceType)
+}
+
+func serviceTypeFromContext(ctx context.Context) string {
+	serviceType, _ := ctx.Value(serviceTypeKey{}).(string)
+	return serviceType
+}
+
+type remotePortHintKey struct{}
+
+// WithRemotePortHint returns a context which hints NAT pinging that the peer was
+// reached on the given remote port last time, e.g. the remote port of a connection
+// from the previous session. If it is one of the candidates, it is pinged first
+// and with full TTL, since peer's NAT mapping is likely still open.
+func WithRemotePortHint(ctx context.Context, port int) context.Context {
+	return context.WithValue(ctx, remotePortHintKey{}, port)
+}
+
+func remotePortHintFromContext(ctx context.Context) int {
+	port, _ := ctx.Value(remotePortHintKey{}).(int)
+	return port
+}
+
+type latencyHintsKey struct{}
+
+// WithLatencyHints returns a context which hints NAT pinging with the expected
+// latency to candidate addresses a peer resolves to, keyed by IP. Hinted
+// candidates are tried first, closest first, the rest keep the resolver order.
+// Hints are advisory only, no candidate is skipped because of them.
+func WithLatencyHints(ctx context.Context, hints map[string]time.Duration) context.Context {
+	return context.WithValue(ctx, latencyHintsKey{}, hints)
+}
+
+func latencyHintsFromContext(ctx context.Context) map[string]time.Duration {
+	hints, _ := ctx.Value(latencyHintsKey{}).(map[string]time.Duration)
+	return hints
+}
+
+type natTypesKey struct{}
+
+// WithNATTypes returns a context which labels NAT pinging with the detected
+// local and remote NAT types, so outcomes are counted per NAT type pair.
+func WithNATTypes(ctx context.Context, local, remote nat.NATType) context.Context {
+	return context.WithValue(ctx, natTypesKey{}, NATTypePair{Local: local, Remote: remote})
+}
+
+func natTypesFromContext(ctx context.Context) (NATTypePair, bool) {
+	pair, ok := ctx.Value(natTypesKey{}).(NATTypePair)
+	return pair, ok
+}
+
+// Detach returns a context which keeps the NAT pinging labels and hints of ctx,
+// but is never canceled and has no deadline, for pinging which must outlive
+// the caller, e.g. a service serving the punched connections.
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
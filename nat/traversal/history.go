@@ -0,0 +1,97 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// historySize is the number of most recent punch outcomes kept per peer.
+	historySize = 20
+	// historyPeers is the number of most recently punched peers history is kept for.
+	historyPeers = 1000
+)
+
+// punchHistory keeps recent hole punching outcomes per remote peer.
+// Peers which were not punched for the longest time are dropped once
+// more than historyPeers are tracked.
+type punchHistory struct {
+	mu       sync.Mutex
+	outcomes map[string]*list.Element
+	recent   list.List
+}
+
+type peerOutcomes struct {
+	remoteIP string
+	outcomes []bool
+}
+
+func (h *punchHistory) add(remoteIP string, success bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.outcomes == nil {
+		h.outcomes = make(map[string]*list.Element)
+	}
+	elem, ok := h.outcomes[remoteIP]
+	if ok {
+		h.recent.MoveToFront(elem)
+	} else {
+		elem = h.recent.PushFront(&peerOutcomes{remoteIP: remoteIP})
+		h.outcomes[remoteIP] = elem
+	}
+
+	peer := elem.Value.(*peerOutcomes)
+	peer.outcomes = append(peer.outcomes, success)
+	if len(peer.outcomes) > historySize {
+		peer.outcomes = peer.outcomes[len(peer.outcomes)-historySize:]
+	}
+
+	if h.recent.Len() > historyPeers {
+		oldest := h.recent.Back()
+		h.recent.Remove(oldest)
+		delete(h.outcomes, oldest.Value.(*peerOutcomes).remoteIP)
+	}
+}
+
+// estimate returns Laplace smoothed success rate of recent outcomes,
+// so peer without history gets 0.5.
+func (h *punchHistory) estimate(remoteIP string) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var successes int
+	var outcomes []bool
+	if elem, ok := h.outcomes[remoteIP]; ok {
+		outcomes = elem.Value.(*peerOutcomes).outcomes
+	}
+	for _, success := range outcomes {
+		if success {
+			successes++
+		}
+	}
+	return float64(successes+1) / float64(len(outcomes)+2)
+}
+
+// SuccessEstimate returns an advisory probability of successful hole punching
+// to the given peer based on recent outcomes. Peers without history get 0.5.
+func (p *Pinger) SuccessEstimate(peerIP string) float64 {
+	return p.history.estimate(peerIP)
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinger_SuccessEstimate(t *testing.T) {
+	pinger := &Pinger{}
+	assert.Equal(t, 0.5, pinger.SuccessEstimate("1.1.1.1"))
+
+	pinger.history.add("1.1.1.1", true)
+	pinger.history.add("1.1.1.1", true)
+	pinger.history.add("1.1.1.1", false)
+	assert.Equal(t, 0.6, pinger.SuccessEstimate("1.1.1.1"))
+	assert.Equal(t, 0.5, pinger.SuccessEstimate("2.2.2.2"))
+
+	for i := 0; i < historySize; i++ {
+		pinger.history.add("1.1.1.1", false)
+	}
+	assert.InDelta(t, 1.0/22, pinger.SuccessEstimate("1.1.1.1"), 1e-9)
+}
+
+func TestPunchHistory_DropsLeastRecentPeers(t *testing.T) {
+	var history punchHistory
+	history.add("1.1.1.1", true)
+	history.add("2.2.2.2", true)
+	for i := 0; i < historyPeers-2; i++ {
+		history.add(fmt.Sprintf("10.0.%d.%d", i/256, i%256), true)
+	}
+	history.add("1.1.1.1", true)
+
+	history.add("3.3.3.3", true)
+
+	assert.Len(t, history.outcomes, historyPeers)
+	assert.Equal(t, 0.75, history.estimate("1.1.1.1"))
+	assert.Equal(t, 0.5, history.estimate("2.2.2.2"))
+	assert.Equal(t, 2.0/3, history.estimate("3.3.3.3"))
+}
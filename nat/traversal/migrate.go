@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"net"
+
+	"github.com/mysteriumnetwork/node/nat/event"
+)
+
+// StageMigration represents connection migration stage of NAT traversal
+const StageMigration = "migration"
+
+// MigrateConsumerPeer re-punches the established connection to the consumer's new
+// address, e.g. after it moved between networks. The same local port is kept, so
+// the consumer has to call MigrateProviderPeer at the same time.
+// The given connection is closed and replaced with the returned one.
+func (p *Pinger) MigrateConsumerPeer(ctx context.Context, id string, conn *net.UDPConn, newPeerAddr *net.UDPAddr) (*net.UDPConn, error) {
+	newConn, err := p.migrate(ctx, conn, newPeerAddr, p.sendConnACK)
+	if err != nil {
+		p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildFailureEvent(id, StageMigration, err).WithServiceType(serviceTypeFromContext(ctx)))
+		return nil, err
+	}
+
+	p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildSuccessfulEvent(id, StageMigration).WithServiceType(serviceTypeFromContext(ctx)))
+	return newConn, nil
+}
+
+// MigrateProviderPeer re-punches the established connection to the provider from the
+// same local port after consumer's own address has changed.
+// The given connection is closed and replaced with the returned one.
+func (p *Pinger) MigrateProviderPeer(ctx context.Context, conn *net.UDPConn, peerAddr *net.UDPAddr) (*net.UDPConn, error) {
+	return p.migrate(ctx, conn, peerAddr, func(ctx context.Context, conn *net.UDPConn) error {
+		if err := p.waitMsg(ctx, conn, msgOK); err != nil {
+			return err
+		}
+		p.sendMsg(conn, msgOKACK)
+		return nil
+	})
+}
+
+func (p *Pinger) migrate(ctx context.Context, conn *net.UDPConn, peerAddr *net.UDPAddr, confirm func(ctx context.Context, conn *net.UDPConn) error) (*net.UDPConn, error) {
+	localPort := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	ctx, cancel := p.clock.WithTimeout(ctx, p.pingConfig.Timeout)
+	defer cancel()
+	defer p.holdPorts([]int{localPort})()
+
+	newConn, err := p.singlePing(ctx, &pingCounters{}, "", peerAddr.IP.String(), localPort, peerAddr.Port, []int{peerAddr.Port}, maxTTL, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	p.sendMsg(newConn, msgOK) // Notify peer that we are using this connection.
+	if err := confirm(ctx, newConn); err != nil {
+		newConn.Close()
+		return nil, err
+	}
+	return newConn, nil
+}
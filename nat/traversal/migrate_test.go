@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mysteriumnetwork/node/core/port"
+	"github.com/mysteriumnetwork/node/nat/event"
+)
+
+func TestPinger_Migrate(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	publisher := &recordingPublisher{}
+	provider := NewPinger(pingConfig, publisher).(*Pinger)
+	consumer := newPinger(pingConfig).(*Pinger)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(2)
+	require.NoError(t, err)
+	pPorts, cPorts := []int{ports[0].Num()}, []int{ports[1].Num()}
+
+	consumerConns := make(chan []*net.UDPConn, 1)
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 1)
+		assert.NoError(t, err)
+		consumerConns <- conns
+	}()
+	providerConns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 1)
+	require.NoError(t, err)
+	cConns := <-consumerConns
+	require.Len(t, cConns, 1)
+
+	migrated := make(chan *net.UDPConn, 1)
+	go func() {
+		conn, err := consumer.MigrateProviderPeer(context.Background(), cConns[0], cConns[0].RemoteAddr().(*net.UDPAddr))
+		assert.NoError(t, err)
+		migrated <- conn
+	}()
+	pConn, err := provider.MigrateConsumerPeer(context.Background(), "id", providerConns[0], providerConns[0].RemoteAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer pConn.Close()
+	cConn := <-migrated
+	require.NotNil(t, cConn)
+	defer cConn.Close()
+
+	assert.Equal(t, pPorts[0], pConn.LocalAddr().(*net.UDPAddr).Port)
+	assert.Equal(t, cPorts[0], cConn.LocalAddr().(*net.UDPAddr).Port)
+	require.Len(t, publisher.events, 2)
+	assert.Equal(t, event.Event{ID: "id", Stage: StageMigration, Successful: true}, publisher.events[1])
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mysteriumnetwork/node/nat"
+)
+
+// NATTypePair is a combination of local and remote NAT types punching happened between.
+type NATTypePair struct {
+	Local  nat.NATType `json:"local"`
+	Remote nat.NATType `json:"remote"`
+}
+
+// OutcomeStats counts punching outcomes.
+type OutcomeStats struct {
+	Successes uint64 `json:"successes"`
+	Failures  uint64 `json:"failures"`
+}
+
+// natTypeStats counts punching outcomes per NAT type pair.
+type natTypeStats struct {
+	mu    sync.Mutex
+	stats map[NATTypePair]OutcomeStats
+}
+
+func (s *natTypeStats) add(pair NATTypePair, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats == nil {
+		s.stats = make(map[NATTypePair]OutcomeStats)
+	}
+	stats := s.stats[pair]
+	if success {
+		stats.Successes++
+	} else {
+		stats.Failures++
+	}
+	s.stats[pair] = stats
+}
+
+func (s *natTypeStats) snapshot() map[NATTypePair]OutcomeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[NATTypePair]OutcomeStats, len(s.stats))
+	for pair, outcome := range s.stats {
+		stats[pair] = outcome
+	}
+	return stats
+}
+
+// recordNATTypeOutcome counts the punching outcome under NAT types from the context, if any.
+func (p *Pinger) recordNATTypeOutcome(ctx context.Context, success bool) {
+	if pair, ok := natTypesFromContext(ctx); ok {
+		p.natTypeStats.add(pair, success)
+	}
+}
+
+// StatsByNATType returns punching outcomes per local and remote NAT type pair.
+// Only pinging labeled with WithNATTypes is taken into account.
+func (p *Pinger) StatsByNATType() map[NATTypePair]OutcomeStats {
+	return p.natTypeStats.snapshot()
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mysteriumnetwork/node/nat"
+)
+
+func TestPinger_StatsByNATType(t *testing.T) {
+	pinger := newPinger(&PingConfig{}).(*Pinger)
+	prToSym := WithNATTypes(context.Background(), nat.NATTypePortRestrictedCone, nat.NATTypeSymmetric)
+	fullToFull := WithNATTypes(context.Background(), nat.NATTypeFullCone, nat.NATTypeFullCone)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pinger.consumerPingResult(prToSym, "id", "10.0.0.1", nil, &PingError{}, &pingCounters{})
+			pinger.providerPingResult(fullToFull, "10.0.0.2", nil, nil, &pingCounters{})
+		}()
+	}
+	wg.Wait()
+	pinger.consumerPingResult(prToSym, "id", "10.0.0.1", nil, nil, &pingCounters{})
+	// Pinging without NAT types is not counted.
+	pinger.providerPingResult(context.Background(), "10.0.0.3", nil, nil, &pingCounters{})
+
+	assert.Equal(t, map[NATTypePair]OutcomeStats{
+		{Local: nat.NATTypePortRestrictedCone, Remote: nat.NATTypeSymmetric}: {Successes: 1, Failures: 10},
+		{Local: nat.NATTypeFullCone, Remote: nat.NATTypeFullCone}:            {Successes: 10},
+	}, pinger.StatsByNATType())
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// PeerSpec describes a peer to ping with PingConsumerPeers.
+type PeerSpec struct {
+	// ID identifies the peer in results and traversal events.
+	ID          string
+	Peer        string
+	LocalPorts  []int
+	RemotePorts []int
+	InitialTTL  int
+	// N is the number of connections to build.
+	N int
+}
+
+// PingResult is the outcome of pinging a single peer.
+type PingResult struct {
+	Conns []*net.UDPConn
+	Err   error
+}
+
+// PingConsumerPeers pings several consumer peers concurrently, e.g. when provider
+// re-establishes connections after a restart. At most PingConfig.MaxConcurrentPeers
+// peers are pinged at once, each one bounded by PingConfig.Timeout.
+// Results are keyed by peer ID.
+func (p *Pinger) PingConsumerPeers(ctx context.Context, peers []PeerSpec) map[string]PingResult {
+	var sem chan struct{}
+	if p.pingConfig.MaxConcurrentPeers > 0 {
+		sem = make(chan struct{}, p.pingConfig.MaxConcurrentPeers)
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]PingResult, len(peers))
+	)
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer PeerSpec) {
+			defer wg.Done()
+
+			var result PingResult
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					result.Err = ctx.Err()
+				}
+			}
+			if result.Err == nil {
+				result.Conns, result.Err = p.PingConsumerPeer(ctx, peer.ID, peer.Peer, peer.LocalPorts, peer.RemotePorts, peer.InitialTTL, peer.N)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[peer.ID] = result
+		}(peer)
+	}
+	wg.Wait()
+
+	return results
+}
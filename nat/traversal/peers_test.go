@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mysteriumnetwork/node/core/port"
+)
+
+func TestPinger_PingConsumerPeers(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+		MaxConcurrentPeers:  1,
+	}
+	provider := newPinger(pingConfig).(*Pinger)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+
+	var peers []PeerSpec
+	for i, id := range []string{"consumer-1", "consumer-2"} {
+		pPorts, cPorts := []int{ports[2*i].Num()}, []int{ports[2*i+1].Num()}
+		peers = append(peers, PeerSpec{ID: id, Peer: "127.0.0.1", LocalPorts: pPorts, RemotePorts: cPorts, InitialTTL: 2, N: 1})
+
+		consumer := newPinger(pingConfig)
+		go func() {
+			conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 1)
+			assert.NoError(t, err)
+			closeConns(conns)
+		}()
+	}
+
+	results := provider.PingConsumerPeers(context.Background(), peers)
+
+	require.Len(t, results, 2)
+	for _, id := range []string{"consumer-1", "consumer-2"} {
+		assert.NoError(t, results[id].Err)
+		assert.Len(t, results[id].Conns, 1)
+		closeConns(results[id].Conns)
+	}
+}
@@ -25,10 +25,12 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/time/rate"
 
 	"github.com/mysteriumnetwork/node/core/port"
 	"github.com/mysteriumnetwork/node/eventbus"
@@ -54,6 +56,165 @@ const (
 // responses to build requested number of connections
 var ErrTooFew = errors.New("too few connections were built")
 
+// ErrPartial indicates the pinging deadline passed with fewer connections
+// than requested, but some were built. They are returned along with the error.
+var ErrPartial = errors.New("pinging deadline passed with too few connections")
+
+// ErrMessageSize indicates a ping did not fit the path MTU while the DF bit was set.
+var ErrMessageSize = errors.New("message too long for unfragmented send")
+
+// ErrLikelyUnreachable indicates pinging was given up early, since every
+// candidate port pair was pinged PingConfig.UnreachableAfter times without
+// a single reply. It is matched by PingError with errors.Is.
+var ErrLikelyUnreachable = errors.New("peer is likely unreachable")
+
+var errNoMapping = errors.New("no remembered mapping")
+
+// PingError describes a pinging attempt which built too few connections.
+// It matches ErrTooFew with errors.Is.
+type PingError struct {
+	Built     int
+	Requested int
+	// Sent and Received count ping packets sent to and received from the peer.
+	Sent     int64
+	Received int64
+	// LastErr is the last local error other than a timeout, if any.
+	LastErr error
+}
+
+// Error returns error description.
+func (e *PingError) Error() string {
+	msg := fmt.Sprintf("%s: built %d of %d, sent %d pings, received %d", ErrTooFew, e.Built, e.Requested, e.Sent, e.Received)
+	if e.LastErr != nil {
+		msg += ", last error: " + e.LastErr.Error()
+	}
+	return msg
+}
+
+// Unwrap returns ErrTooFew.
+func (e *PingError) Unwrap() error {
+	return ErrTooFew
+}
+
+// Is reports whether pinging was given up early as the peer is likely unreachable.
+func (e *PingError) Is(target error) bool {
+	return target == ErrLikelyUnreachable && errors.Is(e.LastErr, ErrLikelyUnreachable)
+}
+
+// pingCounters collects packet counters and errors of pinging a peer. The
+// counters add up over all attempts, e.g. a reuse probe followed by a full
+// ping or retries, while the last error belongs to the current attempt.
+type pingCounters struct {
+	sent     atomic.Int64
+	received atomic.Int64
+	// mismatched counts peer pings observed from a port outside the candidate set.
+	mismatched atomic.Int64
+
+	mu        sync.Mutex
+	lastErr   error
+	phases    map[string]time.Duration
+	punchedAt time.Time
+}
+
+// Phases of a pinging attempt which durations are measured.
+const (
+	phaseResolve     = "resolve"
+	phaseSocketSetup = "socket_setup"
+	phasePunch       = "punch"
+	phaseConfirm     = "confirm"
+)
+
+// observeLongest records phase duration if it is longer than the recorded one.
+func (c *pingCounters) observeLongest(phase string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.phases == nil {
+		c.phases = make(map[string]time.Duration)
+	}
+	if old, ok := c.phases[phase]; !ok || d > old {
+		c.phases[phase] = d
+	}
+}
+
+// punched records how long it took to reach the peer. Only the first
+// socket which reached the peer is taken into account.
+func (c *pingCounters) punched(d time.Duration, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.punchedAt.IsZero() {
+		return
+	}
+	if c.phases == nil {
+		c.phases = make(map[string]time.Duration)
+	}
+	c.phases[phasePunch] = d
+	c.punchedAt = now
+}
+
+// confirmed records how long it took to confirm connections with the peer after it was reached.
+func (c *pingCounters) confirmed(now time.Time) {
+	c.mu.Lock()
+	punchedAt := c.punchedAt
+	c.mu.Unlock()
+
+	if !punchedAt.IsZero() {
+		c.observeLongest(phaseConfirm, now.Sub(punchedAt))
+	}
+}
+
+func (c *pingCounters) phaseDurations() map[string]time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	phases := make(map[string]time.Duration, len(c.phases))
+	for phase, d := range c.phases {
+		phases[phase] = d
+	}
+	return phases
+}
+
+func (c *pingCounters) setErr(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastErr = err
+}
+
+// startAttempt clears the error of the previous attempt and returns packets
+// counted so far, so the attempt can tell its own packets apart.
+func (c *pingCounters) startAttempt() (sent, received int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastErr = nil
+	return c.sent.Load(), c.received.Load()
+}
+
+// doubleNAT reports whether peer was observed from an unexpected port, which
+// means there is one more address translation in front of it (e.g. CGNAT).
+func (c *pingCounters) doubleNAT() bool {
+	return c.mismatched.Load() > 0
+}
+
+func (c *pingCounters) error(built, requested int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &PingError{
+		Built:     built,
+		Requested: requested,
+		Sent:      c.sent.Load(),
+		Received:  c.received.Load(),
+		LastErr:   c.lastErr,
+	}
+}
+
 // NATPinger is responsible for pinging nat holes
 type NATPinger interface {
 	PingProviderPeer(ctx context.Context, localIP, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int) (conns []*net.UDPConn, err error)
@@ -65,14 +226,70 @@ type PingConfig struct {
 	Interval            time.Duration
 	Timeout             time.Duration
 	SendConnACKInterval time.Duration
+	// MinInterval is the floor of Interval, so a misconfigured pinger does not
+	// flood the network. Lower intervals are clamped to it. Zero means no floor.
+	MinInterval time.Duration
+	// ReuseWindow enables reuse of the last successful port mapping to the same peer
+	// if peer is pinged again within the window. Zero disables reuse.
+	ReuseWindow time.Duration
+	// ReuseProbeTimeout limits how long the reused mapping is probed before
+	// falling back to the full ping.
+	ReuseProbeTimeout time.Duration
+	// ReusePort sets SO_REUSEPORT on pinger sockets where supported,
+	// so several processes can share the same listen port.
+	ReusePort bool
+	// DontFragment sets the DF bit on pings where supported, so they either
+	// get through unfragmented or fail with ErrMessageSize.
+	DontFragment bool
+	// PeekLiveness makes pinger peek at incoming data while waiting for peer
+	// messages, so the first service packet is treated as a liveness confirmation
+	// and left in the socket buffer for the service instead of being dropped.
+	// It is ignored on platforms without MSG_PEEK support.
+	PeekLiveness bool
+	// RetryBackoff is the initial delay between ping attempts with retry.
+	// It doubles after every attempt up to RetryMaxBackoff.
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// MaxSendRate limits ping packets per second sent by the pinger across all
+	// its sessions, so multi-port punching does not look like a port scan.
+	// Zero means unlimited.
+	MaxSendRate int
+	// InitiatorDelay postpones sending of pings by the provider side (PingConsumerPeer)
+	// while it already listens, so the consumer initiates and opens its NAT mapping first.
+	// It helps with some NAT combinations, a few Intervals (e.g. 20-50ms) are usually enough.
+	// Zero means both sides start sending immediately.
+	InitiatorDelay time.Duration
+	// PeerResolver resolves peers passed to the pinger to IP addresses,
+	// so callers can use hostnames or peer identifiers. If not set,
+	// peer is treated as a literal address.
+	PeerResolver PeerResolver
+	// QuarantineThreshold is the number of consecutive failures to reach a peer
+	// after which pinging it fails fast with ErrPeerQuarantined until
+	// QuarantineCooldown passes. Zero disables quarantine.
+	QuarantineThreshold int
+	QuarantineCooldown  time.Duration
+	// MaxConcurrentPeers limits how many peers are pinged at once by
+	// PingConsumerPeers. Zero means unlimited.
+	MaxConcurrentPeers int
+	// Tap, if set, observes every packet sent or received while punching,
+	// e.g. to dump the exchange for debugging.
+	Tap PacketTap
+	// UnreachableAfter is the number of pings sent on every candidate port pair
+	// without any reply from the peer, after which pinging fails early with
+	// ErrLikelyUnreachable instead of waiting for Timeout. Zero disables it.
+	UnreachableAfter int
 }
 
 // DefaultPingConfig returns default NAT pinger config.
 func DefaultPingConfig() *PingConfig {
 	return &PingConfig{
 		Interval:            5 * time.Millisecond,
+		MinInterval:         5 * time.Millisecond,
 		Timeout:             10 * time.Second,
 		SendConnACKInterval: 100 * time.Millisecond,
+		ReuseProbeTimeout:   time.Second,
+		RetryBackoff:        100 * time.Millisecond,
+		RetryMaxBackoff:     2 * time.Second,
 	}
 }
 
@@ -80,6 +297,40 @@ func DefaultPingConfig() *PingConfig {
 type Pinger struct {
 	pingConfig     *PingConfig
 	eventPublisher eventbus.Publisher
+	clock          clock
+
+	mu           sync.Mutex
+	mappings     map[string]peerMapping
+	active       map[int]int
+	history      punchHistory
+	quarantine   quarantine
+	natTypeStats natTypeStats
+	limiter      *rate.Limiter
+
+	reclaimed atomic.Int64
+}
+
+// peerMapping holds port pairs of the last successful ping to the peer.
+type peerMapping struct {
+	localPorts  []int
+	remotePorts []int
+	expiresAt   time.Time
+}
+
+// negotiated reports whether every port pair of the mapping is among the given pairs.
+func (m peerMapping) negotiated(localPorts, remotePorts []int) bool {
+	pairs := make(map[[2]int]bool, len(localPorts))
+	for i := range localPorts {
+		if i < len(remotePorts) {
+			pairs[[2]int{localPorts[i], remotePorts[i]}] = true
+		}
+	}
+	for i := range m.localPorts {
+		if !pairs[[2]int{m.localPorts[i], m.remotePorts[i]}] {
+			return false
+		}
+	}
+	return true
 }
 
 // PortSupplier provides port needed to run a service on
@@ -87,14 +338,38 @@ type PortSupplier interface {
 	Acquire() (port.Port, error)
 }
 
+type portReleaser interface {
+	Release(ports ...port.Port)
+}
+
 // NewPinger returns Pinger instance
 func NewPinger(pingConfig *PingConfig, publisher eventbus.Publisher) NATPinger {
+	if pingConfig.Interval < pingConfig.MinInterval {
+		log.Warn().Msgf("NAT ping interval %s is below the minimum, using %s", pingConfig.Interval, pingConfig.MinInterval)
+	}
+
+	var limiter *rate.Limiter
+	if pingConfig.MaxSendRate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(pingConfig.MaxSendRate), 1)
+	}
+
 	return &Pinger{
 		pingConfig:     pingConfig,
 		eventPublisher: publisher,
+		clock:          realClock{},
+		mappings:       make(map[string]peerMapping),
+		limiter:        limiter,
 	}
 }
 
+// interval returns the ping interval clamped to the configured minimum.
+func (p *Pinger) interval() time.Duration {
+	if p.pingConfig.Interval < p.pingConfig.MinInterval {
+		return p.pingConfig.MinInterval
+	}
+	return p.pingConfig.Interval
+}
+
 func drainPingResponses(responses <-chan pingResponse) {
 	for response := range responses {
 		log.Warn().Err(response.err).Msgf("Sanitizing ping response on %#v", response)
@@ -105,25 +380,132 @@ func drainPingResponses(responses <-chan pingResponse) {
 	}
 }
 
-func cleanupConnections(responses []pingResponse) {
-	for _, response := range responses {
-		response.conn.Close()
+func closeConns(conns []*net.UDPConn) {
+	for _, conn := range conns {
+		conn.Close()
 	}
 }
 
 // PingConsumerPeer pings remote peer with a defined configuration
 // and notifies peer which connections will be used.
+// If peer resolves to several addresses, they are tried in order.
 // It returns n connections if possible or error.
-func (p *Pinger) PingConsumerPeer(ctx context.Context, id string, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
-	ctx, cancel := context.WithTimeout(ctx, p.pingConfig.Timeout)
+func (p *Pinger) PingConsumerPeer(ctx context.Context, id string, peer string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		conns, err := p.reuseMapping(ctx, remoteIP, localPorts, remotePorts, n, func(ctx context.Context, localPorts, remotePorts []int) ([]*net.UDPConn, error) {
+			return p.pingConsumerPeer(ctx, counters, remoteIP, localPorts, remotePorts, initialTTL, n)
+		})
+		if err != nil {
+			ctx, cancel := p.clock.WithTimeout(ctx, p.pingConfig.Timeout)
+			defer cancel()
+
+			conns, err = p.pingConsumerPeer(ctx, counters, remoteIP, localPorts, remotePorts, initialTTL, n)
+		}
+		return p.consumerPingResult(ctx, id, remoteIP, conns, err, counters)
+	})
+}
+
+// PingConsumerPeerWithRetry works like PingConsumerPeer, but when too few connections
+// are built it keeps the successful ones and pings only the missing ones again over
+// the unused ports, backing off exponentially until n connections are built or ctx is done.
+func (p *Pinger) PingConsumerPeerWithRetry(ctx context.Context, id string, peer string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		conns, err := p.pingWithRetry(ctx, localPorts, remotePorts, n, func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error) {
+			return p.pingConsumerPeer(ctx, counters, remoteIP, localPorts, remotePorts, initialTTL, n)
+		})
+		return p.consumerPingResult(ctx, id, remoteIP, conns, err, counters)
+	})
+}
+
+// PingConsumerPeerWithDeadline works like PingConsumerPeerWithRetry, but all attempts
+// are bounded by the deadline. If fewer than n connections were built by then, they
+// are returned along with ErrPartial.
+func (p *Pinger) PingConsumerPeerWithDeadline(ctx context.Context, id string, peer string, localPorts, remotePorts []int, initialTTL int, n int, deadline time.Time) ([]*net.UDPConn, error) {
+	ctx, cancel := p.clock.WithTimeout(ctx, deadline.Sub(p.clock.Now()))
 	defer cancel()
 
-	log.Info().Msg("NAT pinging to remote peer")
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		conns, err := p.pingWithRetry(ctx, localPorts, remotePorts, n, func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error) {
+			return p.pingConsumerPeer(ctx, counters, remoteIP, localPorts, remotePorts, initialTTL, n)
+		})
+		if isPartial(conns, err) {
+			return p.consumerPartialPingResult(ctx, id, remoteIP, conns, n, counters)
+		}
+		return p.consumerPingResult(ctx, id, remoteIP, conns, err, counters)
+	})
+}
+
+// PingConsumerPeerAtLeast works like PingConsumerPeer, but returns as soon as min
+// connections are built or deadline passes. If fewer connections were built by
+// the deadline, they are returned along with ErrPartial.
+func (p *Pinger) PingConsumerPeerAtLeast(ctx context.Context, id string, peer string, localPorts, remotePorts []int, initialTTL int, min int, deadline time.Duration) ([]*net.UDPConn, error) {
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		pingCtx, cancel := p.clock.WithTimeout(ctx, deadline)
+		defer cancel()
+
+		conns, err := p.pingConsumerPeer(pingCtx, counters, remoteIP, localPorts, remotePorts, initialTTL, min)
+		if isPartial(conns, err) {
+			return p.consumerPartialPingResult(ctx, id, remoteIP, conns, min, counters)
+		}
+		return p.consumerPingResult(ctx, id, remoteIP, conns, err, counters)
+	})
+}
+
+// isPartial reports whether pinging failed with too few connections, but some were built.
+func isPartial(conns []*net.UDPConn, err error) bool {
+	return errors.Is(err, ErrTooFew) && len(conns) > 0
+}
+
+// partialPingResult returns connections built before the deadline along with ErrPartial.
+// The attempt is recorded as a failure and its mapping is not remembered for reuse.
+func (p *Pinger) partialPingResult(ctx context.Context, remoteIP string, conns []*net.UDPConn, want int, counters *pingCounters) ([]*net.UDPConn, error) {
+	log.Warn().Msgf("Built %d of %d connections before the deadline", len(conns), want)
+	log.Debug().Msgf("NAT pinging phase durations: %v", counters.phaseDurations())
+	p.recordOutcome(remoteIP, false)
+	p.recordNATTypeOutcome(ctx, false)
+	return conns, ErrPartial
+}
+
+func (p *Pinger) consumerPartialPingResult(ctx context.Context, id, remoteIP string, conns []*net.UDPConn, want int, counters *pingCounters) ([]*net.UDPConn, error) {
+	p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildFailureEvent(id, StageName, ErrPartial).
+		WithServiceType(serviceTypeFromContext(ctx)).WithDoubleNAT(counters.doubleNAT()).WithPhases(counters.phaseDurations()))
+	return p.partialPingResult(ctx, remoteIP, conns, want, counters)
+}
+
+func (p *Pinger) consumerPingResult(ctx context.Context, id, remoteIP string, conns []*net.UDPConn, err error, counters *pingCounters) ([]*net.UDPConn, error) {
+	serviceType := serviceTypeFromContext(ctx)
+	phases := counters.phaseDurations()
+	log.Debug().Msgf("NAT pinging phase durations: %v", phases)
+	if err != nil {
+		closeConns(conns)
+		if errors.Is(err, ErrTooFew) {
+			p.recordOutcome(remoteIP, false)
+			p.recordNATTypeOutcome(ctx, false)
+			p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildFailureEvent(id, StageName, err).
+				WithServiceType(serviceType).WithDoubleNAT(counters.doubleNAT()).WithPhases(phases))
+		}
+		return nil, err
+	}
+
+	p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildSuccessfulEvent(id, StageName).
+		WithServiceType(serviceType).WithDoubleNAT(counters.doubleNAT()).WithPhases(phases))
+	p.recordOutcome(remoteIP, true)
+	p.recordNATTypeOutcome(ctx, true)
+	p.rememberMapping(remoteIP, conns)
+	return conns, nil
+}
+
+func (p *Pinger) pingConsumerPeer(ctx context.Context, counters *pingCounters, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
+	log.Info().Str("service_type", serviceTypeFromContext(ctx)).Msg("NAT pinging to remote peer")
 
 	stop := make(chan struct{})
 	defer close(stop)
 
-	ch, err := p.multiPingN(ctx, "", remoteIP, localPorts, remotePorts, initialTTL, n)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer p.holdPorts(localPorts)()
+
+	ch, err := p.multiPingN(ctx, counters, "", remoteIP, localPorts, remotePorts, initialTTL, n, p.pingConfig.InitiatorDelay)
 	if err != nil {
 		log.Err(err).Msg("Failed to ping remote peer")
 		return nil, err
@@ -137,6 +519,7 @@ func (p *Pinger) PingConsumerPeer(ctx context.Context, id string, remoteIP strin
 				if !errors.Is(res.err, context.Canceled) {
 					log.Warn().Err(res.err).Msg("One of the pings has error")
 				}
+				counters.setErr(res.err)
 				continue
 			}
 
@@ -169,27 +552,105 @@ func (p *Pinger) PingConsumerPeer(ctx context.Context, id string, remoteIP strin
 	for ping := range pingsCh {
 		pings = append(pings, ping)
 		if len(pings) == n {
-			p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildSuccessfulEvent(id, StageName))
-			go drainPingResponses(pingsCh)
+			counters.confirmed(p.clock.Now())
+			p.reclaimUnused(cancel, pingsCh, len(localPorts)-n)
 			return sortedConns(pings), nil
 		}
 	}
 
-	p.eventPublisher.Publish(event.AppTopicTraversal, event.BuildFailureEvent(id, StageName, ErrTooFew))
-	cleanupConnections(pings)
-	return nil, ErrTooFew
+	return sortedConns(pings), counters.error(len(pings), n)
 }
 
 // PingProviderPeer pings remote peer with a defined configuration
 // and waits for peer to send ack with connection selected ids.
+// If peer resolves to several addresses, they are tried in order.
 // It returns n connections if possible or error.
-func (p *Pinger) PingProviderPeer(ctx context.Context, localIP, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
-	ctx, cancel := context.WithTimeout(ctx, p.pingConfig.Timeout)
+func (p *Pinger) PingProviderPeer(ctx context.Context, localIP, peer string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		conns, err := p.reuseMapping(ctx, remoteIP, localPorts, remotePorts, n, func(ctx context.Context, localPorts, remotePorts []int) ([]*net.UDPConn, error) {
+			return p.pingProviderPeer(ctx, counters, localIP, remoteIP, localPorts, remotePorts, initialTTL, n)
+		})
+		if err != nil {
+			ctx, cancel := p.clock.WithTimeout(ctx, p.pingConfig.Timeout)
+			defer cancel()
+
+			conns, err = p.pingProviderPeer(ctx, counters, localIP, remoteIP, localPorts, remotePorts, initialTTL, n)
+		}
+		return p.providerPingResult(ctx, remoteIP, conns, err, counters)
+	})
+}
+
+// PingProviderPeerWithRetry works like PingProviderPeer, but when too few connections
+// are built it keeps the successful ones and pings only the missing ones again over
+// the unused ports, backing off exponentially until n connections are built or ctx is done.
+func (p *Pinger) PingProviderPeerWithRetry(ctx context.Context, localIP, peer string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		conns, err := p.pingWithRetry(ctx, localPorts, remotePorts, n, func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error) {
+			return p.pingProviderPeer(ctx, counters, localIP, remoteIP, localPorts, remotePorts, initialTTL, n)
+		})
+		return p.providerPingResult(ctx, remoteIP, conns, err, counters)
+	})
+}
+
+// PingProviderPeerWithDeadline works like PingProviderPeerWithRetry, but all attempts
+// are bounded by the deadline. If fewer than n connections were built by then, they
+// are returned along with ErrPartial.
+func (p *Pinger) PingProviderPeerWithDeadline(ctx context.Context, localIP, peer string, localPorts, remotePorts []int, initialTTL int, n int, deadline time.Time) ([]*net.UDPConn, error) {
+	ctx, cancel := p.clock.WithTimeout(ctx, deadline.Sub(p.clock.Now()))
 	defer cancel()
 
-	log.Info().Msg("NAT pinging to remote peer")
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		conns, err := p.pingWithRetry(ctx, localPorts, remotePorts, n, func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error) {
+			return p.pingProviderPeer(ctx, counters, localIP, remoteIP, localPorts, remotePorts, initialTTL, n)
+		})
+		if isPartial(conns, err) {
+			return p.partialPingResult(ctx, remoteIP, conns, n, counters)
+		}
+		return p.providerPingResult(ctx, remoteIP, conns, err, counters)
+	})
+}
 
-	ch, err := p.multiPingN(ctx, localIP, remoteIP, localPorts, remotePorts, initialTTL, n)
+// PingProviderPeerAtLeast works like PingProviderPeer, but returns as soon as min
+// connections are built or deadline passes. If fewer connections were built by
+// the deadline, they are returned along with ErrPartial.
+func (p *Pinger) PingProviderPeerAtLeast(ctx context.Context, localIP, peer string, localPorts, remotePorts []int, initialTTL int, min int, deadline time.Duration) ([]*net.UDPConn, error) {
+	return p.pingResolved(ctx, peer, func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error) {
+		pingCtx, cancel := p.clock.WithTimeout(ctx, deadline)
+		defer cancel()
+
+		conns, err := p.pingProviderPeer(pingCtx, counters, localIP, remoteIP, localPorts, remotePorts, initialTTL, min)
+		if isPartial(conns, err) {
+			return p.partialPingResult(ctx, remoteIP, conns, min, counters)
+		}
+		return p.providerPingResult(ctx, remoteIP, conns, err, counters)
+	})
+}
+
+func (p *Pinger) providerPingResult(ctx context.Context, remoteIP string, conns []*net.UDPConn, err error, counters *pingCounters) ([]*net.UDPConn, error) {
+	log.Debug().Msgf("NAT pinging phase durations: %v", counters.phaseDurations())
+	if err != nil {
+		closeConns(conns)
+		if errors.Is(err, ErrTooFew) {
+			p.recordOutcome(remoteIP, false)
+			p.recordNATTypeOutcome(ctx, false)
+		}
+		return nil, err
+	}
+
+	p.recordOutcome(remoteIP, true)
+	p.recordNATTypeOutcome(ctx, true)
+	p.rememberMapping(remoteIP, conns)
+	return conns, nil
+}
+
+func (p *Pinger) pingProviderPeer(ctx context.Context, counters *pingCounters, localIP, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int) ([]*net.UDPConn, error) {
+	log.Info().Str("service_type", serviceTypeFromContext(ctx)).Msg("NAT pinging to remote peer")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer p.holdPorts(localPorts)()
+
+	ch, err := p.multiPingN(ctx, counters, localIP, remoteIP, localPorts, remotePorts, initialTTL, n, 0)
 	if err != nil {
 		log.Err(err).Msg("Failed to ping remote peer")
 		return nil, err
@@ -203,6 +664,7 @@ func (p *Pinger) PingProviderPeer(ctx context.Context, localIP, remoteIP string,
 				if !errors.Is(res.err, context.Canceled) {
 					log.Warn().Err(res.err).Msg("One of the pings has error")
 				}
+				counters.setErr(res.err)
 				continue
 			}
 
@@ -237,13 +699,190 @@ func (p *Pinger) PingProviderPeer(ctx context.Context, localIP, remoteIP string,
 		pings = append(pings, ping)
 		p.sendMsg(ping.conn, msgOKACK)
 		if len(pings) == n {
-			go drainPingResponses(pingsCh)
+			counters.confirmed(p.clock.Now())
+			p.reclaimUnused(cancel, pingsCh, len(localPorts)-n)
 			return sortedConns(pings), nil
 		}
 	}
 
-	cleanupConnections(pings)
-	return nil, ErrTooFew
+	return sortedConns(pings), counters.error(len(pings), n)
+}
+
+// reclaimUnused stops pinging of the remaining candidate ports and waits until
+// their sockets are closed, so the ports can be acquired again right away.
+func (p *Pinger) reclaimUnused(cancel context.CancelFunc, responses <-chan pingResponse, unused int) {
+	cancel()
+	drainPingResponses(responses)
+	p.reclaimed.Add(int64(unused))
+	log.Debug().Msgf("Reclaimed %d unused candidate ports", unused)
+}
+
+// ReclaimedPorts returns the total number of unused candidate ports
+// released after successful pings.
+func (p *Pinger) ReclaimedPorts() int64 {
+	return p.reclaimed.Load()
+}
+
+// pingWithRetry calls ping until n connections are built. Connections built by a
+// failed attempt are kept and the next attempt pings only the missing ones using
+// port pairs which are not taken yet. It gives up when ctx is done, ports run out
+// or ping fails with anything else than ErrTooFew, returning connections built so far.
+func (p *Pinger) pingWithRetry(ctx context.Context, localPorts, remotePorts []int, n int, ping func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error)) ([]*net.UDPConn, error) {
+	if len(localPorts) != len(remotePorts) {
+		return nil, errors.New("number of local and remote ports does not match")
+	}
+
+	var conns []*net.UDPConn
+	backoff := p.pingConfig.RetryBackoff
+	for {
+		attemptCtx, cancel := p.clock.WithTimeout(ctx, p.pingConfig.Timeout)
+		built, err := ping(attemptCtx, localPorts, remotePorts, n-len(conns))
+		cancel()
+
+		conns = append(conns, built...)
+		if err == nil && len(conns) >= n {
+			return conns, nil
+		}
+		if err != nil && (!errors.Is(err, ErrTooFew) || errors.Is(err, ErrLikelyUnreachable)) {
+			return conns, err
+		}
+
+		localPorts, remotePorts = unusedPorts(localPorts, remotePorts, built)
+		if len(localPorts) < n-len(conns) {
+			return conns, err
+		}
+
+		log.Debug().Msgf("Built %d of %d connections, retrying in %s", len(conns), n, backoff)
+		select {
+		case <-ctx.Done():
+			return conns, err
+		case <-p.clock.After(backoff):
+		}
+
+		backoff *= 2
+		if p.pingConfig.RetryMaxBackoff > 0 && backoff > p.pingConfig.RetryMaxBackoff {
+			backoff = p.pingConfig.RetryMaxBackoff
+		}
+	}
+}
+
+// unusedPorts returns port pairs which local port is not taken by any of given connections.
+func unusedPorts(localPorts, remotePorts []int, conns []*net.UDPConn) (unusedLocal, unusedRemote []int) {
+	used := make(map[int]bool, len(conns))
+	for _, conn := range conns {
+		used[conn.LocalAddr().(*net.UDPAddr).Port] = true
+	}
+
+	for i := range localPorts {
+		if !used[localPorts[i]] {
+			unusedLocal = append(unusedLocal, localPorts[i])
+			unusedRemote = append(unusedRemote, remotePorts[i])
+		}
+	}
+	return unusedLocal, unusedRemote
+}
+
+// ActivePorts returns a sorted snapshot of local ports used by in-flight pings.
+func (p *Pinger) ActivePorts() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ports := make([]int, 0, len(p.active))
+	for port := range p.active {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// holdPorts marks ports as used by in-flight ping until returned func is called.
+func (p *Pinger) holdPorts(ports []int) (release func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.active == nil {
+		p.active = make(map[int]int)
+	}
+	for _, port := range ports {
+		p.active[port]++
+	}
+
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		for _, port := range ports {
+			p.active[port]--
+			if p.active[port] <= 0 {
+				delete(p.active, port)
+			}
+		}
+	}
+}
+
+// Forget drops the remembered port mapping of the given peer.
+func (p *Pinger) Forget(remoteIP string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.mappings, remoteIP)
+}
+
+// reuseMapping probes the remembered port mapping of the peer using given ping function.
+// The mapping is only probed if all its port pairs were negotiated again, since the
+// peer only listens on the negotiated ports. It returns an error if there is no such
+// mapping or the probe fails.
+func (p *Pinger) reuseMapping(ctx context.Context, remoteIP string, localPorts, remotePorts []int, n int, ping func(ctx context.Context, localPorts, remotePorts []int) ([]*net.UDPConn, error)) ([]*net.UDPConn, error) {
+	if p.pingConfig.ReuseWindow <= 0 {
+		return nil, errNoMapping
+	}
+
+	p.mu.Lock()
+	mapping, ok := p.mappings[remoteIP]
+	if ok && p.clock.Now().After(mapping.expiresAt) {
+		delete(p.mappings, remoteIP)
+		ok = false
+	}
+	p.mu.Unlock()
+
+	if !ok || len(mapping.localPorts) < n || !mapping.negotiated(localPorts, remotePorts) {
+		return nil, errNoMapping
+	}
+
+	timeout := p.pingConfig.ReuseProbeTimeout
+	if timeout <= 0 {
+		timeout = p.pingConfig.Timeout
+	}
+	ctx, cancel := p.clock.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Debug().Msgf("Probing remembered mapping to %s using ports %v:%v", remoteIP, mapping.localPorts, mapping.remotePorts)
+	conns, err := ping(ctx, mapping.localPorts, mapping.remotePorts)
+	if err != nil {
+		closeConns(conns)
+		p.Forget(remoteIP)
+		log.Debug().Err(err).Msgf("Remembered mapping to %s is not alive, doing full ping", remoteIP)
+		return nil, err
+	}
+	return conns, nil
+}
+
+func (p *Pinger) rememberMapping(remoteIP string, conns []*net.UDPConn) {
+	if p.pingConfig.ReuseWindow <= 0 {
+		return
+	}
+
+	var mapping peerMapping
+	for _, conn := range conns {
+		mapping.localPorts = append(mapping.localPorts, conn.LocalAddr().(*net.UDPAddr).Port)
+		mapping.remotePorts = append(mapping.remotePorts, conn.RemoteAddr().(*net.UDPAddr).Port)
+	}
+	mapping.expiresAt = p.clock.Now().Add(p.pingConfig.ReuseWindow)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.mappings[remoteIP] = mapping
 }
 
 // sendConnACK notifies peer that we are using this connection
@@ -258,7 +897,7 @@ func (p *Pinger) sendConnACK(ctx context.Context, conn *net.UDPConn) error {
 		select {
 		case err := <-ackWaitErr:
 			return err
-		case <-time.After(p.pingConfig.SendConnACKInterval):
+		case <-p.clock.After(p.pingConfig.SendConnACKInterval):
 			p.sendMsg(conn, msgOK)
 		}
 	}
@@ -285,17 +924,24 @@ func (p *Pinger) waitMsg(ctx context.Context, conn *net.UDPConn, msg string) err
 	// just reasonable upper boundary for receive errors to not enter infinite
 	// loop on closed socket, but still skim errors of closed port etc
 	// +1 in denominator is to avoid division by zero
-	recvErrLimit := 2 * int(p.pingConfig.Timeout/(p.pingConfig.Interval+1))
+	recvErrLimit := 2 * int(p.pingConfig.Timeout/(p.interval()+1))
 	for errCount := 0; errCount < recvErrLimit; {
-		n, err = readFromConnWithContext(ctx, conn, buf)
+		n, err = p.readMsg(ctx, conn, buf)
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
+		if n > 0 {
+			p.tap(PacketReceived, buf[:n], conn.RemoteAddr())
+		}
 		// process returned data unconditionally as io.Reader dictates to
 		v := string(buf[:n])
 		if v == msg {
 			return nil
 		}
+		if p.peekLiveness() && n > 0 && !isPingerMsg(v) {
+			// Peer is already sending service data, leave it for the service.
+			return nil
+		}
 		if err != nil {
 			errCount++
 			log.Error().Err(err).Msgf("got error in waitMsg, trying to recover. %d attempts left",
@@ -306,36 +952,73 @@ func (p *Pinger) waitMsg(ctx context.Context, conn *net.UDPConn, msg string) err
 	return fmt.Errorf("too many recv errors, last one: %w", err)
 }
 
+// readMsg reads next message from conn. In peek mode only pinger messages are
+// consumed, any other data is left in the socket buffer.
+func (p *Pinger) readMsg(ctx context.Context, conn *net.UDPConn, buf []byte) (int, error) {
+	if !p.peekLiveness() {
+		return readFromConnWithContext(ctx, conn, buf)
+	}
+
+	n, err := peekFromUDPWithContext(ctx, conn, buf)
+	if err != nil || !isPingerMsg(string(buf[:n])) {
+		return n, err
+	}
+	return readFromConnWithContext(ctx, conn, buf)
+}
+
+func (p *Pinger) peekLiveness() bool {
+	return p.pingConfig.PeekLiveness && peekSupported
+}
+
+func isPingerMsg(msg string) bool {
+	return msg == msgOK || msg == msgOKACK || strings.HasPrefix(msg, msgPing)
+}
+
 func (p *Pinger) sendMsg(conn *net.UDPConn, msg string) {
 	for i := 0; i < sendRetries; i++ {
 		_, err := conn.Write([]byte(msg))
 		if err != nil {
 			log.Error().Err(err).Msg("pinger message send failed")
-			time.Sleep(p.pingConfig.Interval)
+			<-p.clock.After(p.interval())
 		} else {
+			p.tap(PacketSent, []byte(msg), conn.RemoteAddr())
 			return
 		}
 	}
 }
 
-func (p *Pinger) ping(ctx context.Context, conn *net.UDPConn, remoteAddr *net.UDPAddr, ttl int) error {
+func (p *Pinger) ping(ctx context.Context, counters *pingCounters, conn *net.UDPConn, remoteAddr *net.UDPAddr, ttl int, delay time.Duration) error {
 	err := ipv4.NewConn(conn).SetTTL(ttl)
 	if err != nil {
 		return fmt.Errorf("pinger setting ttl failed: %w", err)
 	}
 
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.clock.After(delay):
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-time.After(p.pingConfig.Interval):
-			_, err := conn.WriteToUDP([]byte(msgPing+remoteAddr.String()), remoteAddr)
+		case <-p.clock.After(p.interval()):
+			if p.limiter != nil && p.limiter.Wait(ctx) != nil {
+				return nil
+			}
+			msg := []byte(msgPing + remoteAddr.String())
+			_, err := conn.WriteToUDP(msg, remoteAddr)
 			if ctx.Err() != nil {
 				return nil
 			}
 			if err != nil {
-				return fmt.Errorf("pinging request failed: %w", err)
+				return fmt.Errorf("pinging request failed: %w", sendError(err))
 			}
+			p.tap(PacketSent, msg, remoteAddr)
+			counters.sent.Add(1)
 		}
 	}
 }
@@ -358,6 +1041,24 @@ func readFromConnWithContext(ctx context.Context, conn net.Conn, buf []byte) (n
 	}
 }
 
+func peekFromUDPWithContext(ctx context.Context, conn *net.UDPConn, buf []byte) (n int, err error) {
+	readDone := make(chan struct{})
+	go func() {
+		n, err = peekUDP(conn, buf)
+		close(readDone)
+	}()
+
+	select {
+	case <-ctx.Done():
+		conn.SetReadDeadline(time.Unix(0, 0))
+		<-readDone
+		conn.SetReadDeadline(time.Time{})
+		return 0, ctx.Err()
+	case <-readDone:
+		return
+	}
+}
+
 func readFromUDPWithContext(ctx context.Context, conn *net.UDPConn, buf []byte) (n int, from *net.UDPAddr, err error) {
 	readDone := make(chan struct{})
 	go func() {
@@ -376,7 +1077,7 @@ func readFromUDPWithContext(ctx context.Context, conn *net.UDPConn, buf []byte)
 	}
 }
 
-func (p *Pinger) pingReceiver(ctx context.Context, conn *net.UDPConn) (*net.UDPAddr, error) {
+func (p *Pinger) pingReceiver(ctx context.Context, counters *pingCounters, conn *net.UDPConn) (*net.UDPAddr, error) {
 	buf := make([]byte, bufferLen)
 
 	for {
@@ -390,6 +1091,8 @@ func (p *Pinger) pingReceiver(ctx context.Context, conn *net.UDPConn) (*net.UDPA
 			continue
 		}
 
+		counters.received.Add(1)
+		p.tap(PacketReceived, buf[:n], raddr)
 		msg := string(buf[:n])
 		log.Debug().Msgf("Remote peer data received, len: %d", n)
 
@@ -406,30 +1109,63 @@ func (p *Pinger) Valid() bool {
 	return true
 }
 
+// Healthy checks that the pinger can acquire a port from the pool and bind
+// a UDP socket on it, so traversal is not doomed to fail e.g. because ports
+// are exhausted or binding is not permitted. The port is released afterwards
+// if the supplier supports it.
+func (p *Pinger) Healthy(ports PortSupplier) error {
+	port, err := ports.Acquire()
+	if err != nil {
+		return fmt.Errorf("failed to acquire port for NAT pinging: %w", err)
+	}
+	if releaser, ok := ports.(portReleaser); ok {
+		defer releaser.Release(port)
+	}
+
+	conn, err := p.listenUDP(&net.UDPAddr{Port: port.Num()})
+	if err != nil {
+		return fmt.Errorf("failed to bind UDP socket on port %d: %w", port.Num(), err)
+	}
+	return conn.Close()
+}
+
 type pingResponse struct {
 	conn *net.UDPConn
 	err  error
 	id   int
 }
 
-func (p *Pinger) multiPingN(ctx context.Context, localIP, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int) (<-chan pingResponse, error) {
+func (p *Pinger) multiPingN(ctx context.Context, counters *pingCounters, localIP, remoteIP string, localPorts, remotePorts []int, initialTTL int, n int, delay time.Duration) (<-chan pingResponse, error) {
 	if len(localPorts) != len(remotePorts) {
 		return nil, errors.New("number of local and remote ports does not match")
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	sent, received := counters.startAttempt()
+	if limit := p.pingConfig.UnreachableAfter; limit > 0 {
+		go p.watchUnreachable(ctx, cancel, counters, sent, received, int64(limit*len(localPorts)))
+	}
+
 	var wg sync.WaitGroup
 	ch := make(chan pingResponse, len(localPorts))
 	ttl := initialTTL
 	resetTTL := initialTTL + (len(localPorts) / n)
+	hint := remotePortHintFromContext(ctx)
 
 	for i := range localPorts {
 		wg.Add(1)
 
-		go func(i, ttl int) {
+		pairTTL, pairDelay := ttl, delay
+		if hint != 0 && remotePorts[i] == hint {
+			log.Debug().Msgf("Pinging hinted remote port %d first", hint)
+			pairTTL, pairDelay = maxTTL, 0
+		}
+
+		go func(i, ttl int, delay time.Duration) {
 			defer wg.Done()
-			conn, err := p.singlePing(ctx, localIP, remoteIP, localPorts[i], remotePorts[i], ttl)
+			conn, err := p.singlePing(ctx, counters, localIP, remoteIP, localPorts[i], remotePorts[i], remotePorts, ttl, delay)
 			ch <- pingResponse{conn: conn, err: err, id: i}
-		}(i, ttl)
+		}(i, pairTTL, pairDelay)
 
 		// TTL increase is only needed for provider side which starts with low TTL value.
 		if ttl < maxTTL {
@@ -440,13 +1176,48 @@ func (p *Pinger) multiPingN(ctx context.Context, localIP, remoteIP string, local
 		}
 	}
 
-	go func() { wg.Wait(); close(ch) }()
+	go func() { wg.Wait(); cancel(); close(ch) }()
 
 	return ch, nil
 }
 
-func (p *Pinger) singlePing(ctx context.Context, localIP, remoteIP string, localPort, remotePort, ttl int) (*net.UDPConn, error) {
-	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(localIP), Port: localPort})
+// watchUnreachable cancels pinging once the given number of pings were sent
+// without any reply from the peer. Packets counted before the attempt started,
+// given as sent and received, are not taken into account.
+func (p *Pinger) watchUnreachable(ctx context.Context, cancel context.CancelFunc, counters *pingCounters, sent, received, limit int64) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(p.interval()):
+		}
+
+		if counters.received.Load() > received {
+			return
+		}
+		if counters.sent.Load()-sent >= limit {
+			log.Debug().Msgf("No reply after %d pings, giving up", limit)
+			counters.setErr(ErrLikelyUnreachable)
+			cancel()
+			return
+		}
+	}
+}
+
+func containsPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// singlePing punches a hole from localPort to remotePort. Candidates hold all
+// remote ports announced by the peer, a reply from any of them is expected.
+func (p *Pinger) singlePing(ctx context.Context, counters *pingCounters, localIP, remoteIP string, localPort, remotePort int, candidates []int, ttl int, delay time.Duration) (*net.UDPConn, error) {
+	start := p.clock.Now()
+	conn, err := p.listenUDP(&net.UDPAddr{IP: net.ParseIP(localIP), Port: localPort})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get connection: %w", err)
 	}
@@ -458,6 +1229,8 @@ func (p *Pinger) singlePing(ctx context.Context, localIP, remoteIP string, local
 	}
 
 	log.Debug().Msgf("Local socket: %s", conn.LocalAddr())
+	punchStart := p.clock.Now()
+	counters.observeLongest(phaseSocketSetup, punchStart.Sub(start))
 
 	remoteAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", remoteIP, remotePort))
 	if err != nil {
@@ -466,22 +1239,29 @@ func (p *Pinger) singlePing(ctx context.Context, localIP, remoteIP string, local
 
 	ctx1, cl := context.WithCancel(ctx)
 	go func() {
-		err := p.ping(ctx1, conn, remoteAddr, ttl)
+		err := p.ping(ctx1, counters, conn, remoteAddr, ttl, delay)
 		if err != nil {
+			counters.setErr(err)
 			log.Warn().Err(err).Msg("Error while pinging")
 		}
 	}()
 
 	laddr := conn.LocalAddr().(*net.UDPAddr)
-	raddr, err := p.pingReceiver(ctx, conn)
+	raddr, err := p.pingReceiver(ctx, counters, conn)
 	cl()
 	if err != nil {
 		return nil, fmt.Errorf("ping receiver error: %w", err)
 	}
+	now := p.clock.Now()
+	counters.punched(now.Sub(punchStart), now)
+	if !containsPort(candidates, raddr.Port) {
+		counters.mismatched.Add(1)
+		log.Debug().Msgf("Remote peer observed on port %d instead of %d, it is likely behind double NAT", raddr.Port, remotePort)
+	}
 	// need to dial same connection further
 	conn.Close()
 
-	newConn, err := net.DialUDP("udp4", laddr, raddr)
+	newConn, err := p.dialUDP(laddr, raddr)
 	if err != nil {
 		return nil, err
 	}
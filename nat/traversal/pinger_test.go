@@ -19,8 +19,13 @@ package traversal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -28,6 +33,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/mysteriumnetwork/node/core/port"
+	"github.com/mysteriumnetwork/node/nat/event"
+	"github.com/mysteriumnetwork/node/nat/traversal/traversaltest"
 )
 
 const portCount = 10
@@ -77,6 +84,191 @@ func TestPinger_PingPeer_N_Connections(t *testing.T) {
 	assert.Equal(t, conn2.RemoteAddr().(*net.UDPAddr).Port, peerConn2.LocalAddr().(*net.UDPAddr).Port)
 }
 
+func TestPinger_Tap(t *testing.T) {
+	var (
+		mu                 sync.Mutex
+		sent, received     []string
+		sentTo, receivedOn = make(map[int]bool), make(map[int]bool)
+	)
+	providerConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+		Tap: func(dir PacketDirection, data []byte, peer net.Addr) {
+			mu.Lock()
+			defer mu.Unlock()
+			port := peer.(*net.UDPAddr).Port
+			if dir == PacketSent {
+				sent = append(sent, string(data))
+				sentTo[port] = true
+			} else {
+				received = append(received, string(data))
+				receivedOn[port] = true
+			}
+		},
+	}
+	consumerConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	provider := newPinger(providerConfig)
+	consumer := newPinger(consumerConfig)
+
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(2)
+	require.NoError(t, err)
+	pPort, cPort := ports[0].Num(), ports[1].Num()
+
+	consumerConns := make(chan []*net.UDPConn, 1)
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", []int{cPort}, []int{pPort}, 128, 1)
+		assert.NoError(t, err)
+		consumerConns <- conns
+	}()
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", []int{pPort}, []int{cPort}, 2, 1)
+	require.NoError(t, err)
+	closeConns(conns)
+	closeConns(<-consumerConns)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, sent, msgPing+fmt.Sprintf("127.0.0.1:%d", cPort))
+	assert.Contains(t, sent, msgOK)
+	assert.Contains(t, received, msgOKACK)
+	assert.Equal(t, map[int]bool{cPort: true}, sentTo)
+	assert.Equal(t, map[int]bool{cPort: true}, receivedOn)
+}
+
+func TestPinger_PingPeer_SimulatedNAT(t *testing.T) {
+	for _, tc := range []struct {
+		provider, consumer traversaltest.Type
+		punched            bool
+	}{
+		{provider: traversaltest.FullCone, consumer: traversaltest.FullCone, punched: true},
+		{provider: traversaltest.Restricted, consumer: traversaltest.PortRestricted, punched: true},
+		{provider: traversaltest.PortRestricted, consumer: traversaltest.PortRestricted, punched: true},
+		{provider: traversaltest.Symmetric, consumer: traversaltest.FullCone, punched: true},
+		{provider: traversaltest.Symmetric, consumer: traversaltest.PortRestricted, punched: false},
+		{provider: traversaltest.Symmetric, consumer: traversaltest.Symmetric, punched: false},
+	} {
+		t.Run(tc.provider.String()+"/"+tc.consumer.String(), func(t *testing.T) {
+			pingConfig := &PingConfig{
+				Interval:            5 * time.Millisecond,
+				SendConnACKInterval: 5 * time.Millisecond,
+				Timeout:             time.Second,
+			}
+			provider := newPinger(pingConfig)
+			consumer := newPinger(pingConfig)
+
+			network := traversaltest.NewNetwork()
+			defer network.Close()
+			providerNAT, err := network.NewNAT(tc.provider)
+			require.NoError(t, err)
+			consumerNAT, err := network.NewNAT(tc.consumer)
+			require.NoError(t, err)
+
+			var pPorts, cPorts []int
+			ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(portCount * 2)
+			require.NoError(t, err)
+			for i := 0; i < portCount; i++ {
+				pPorts = append(pPorts, ports[i].Num())
+				cPorts = append(cPorts, ports[portCount+i].Num())
+			}
+			pExternal, err := providerNAT.Expose(pPorts...)
+			require.NoError(t, err)
+			cExternal, err := consumerNAT.Expose(cPorts...)
+			require.NoError(t, err)
+
+			consumerErr := make(chan error, 1)
+			go func() {
+				conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pExternal, 128, 2)
+				closeConns(conns)
+				consumerErr <- err
+			}()
+			conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cExternal, 2, 2)
+			closeConns(conns)
+
+			if tc.punched {
+				assert.NoError(t, err)
+				assert.Len(t, conns, 2)
+				assert.NoError(t, <-consumerErr)
+			} else {
+				assert.ErrorIs(t, err, ErrTooFew)
+				assert.ErrorIs(t, <-consumerErr, ErrTooFew)
+			}
+		})
+	}
+}
+
+func TestPinger_PingPeer_ReclaimsUnusedPorts(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	provider := newPinger(pingConfig).(*Pinger)
+	consumer := newPinger(pingConfig).(*Pinger)
+	var pPorts, cPorts []int
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(portCount * 2)
+	require.NoError(t, err)
+	for i := 0; i < portCount; i++ {
+		pPorts = append(pPorts, ports[i].Num())
+		cPorts = append(cPorts, ports[portCount+i].Num())
+	}
+
+	consumerConns := make(chan []*net.UDPConn, 1)
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 2)
+		assert.NoError(t, err)
+		consumerConns <- conns
+	}()
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+	require.NoError(t, err)
+	conns = append(conns, <-consumerConns...)
+	defer closeConns(conns)
+
+	used := make(map[int]bool)
+	for _, conn := range conns {
+		used[conn.LocalAddr().(*net.UDPAddr).Port] = true
+	}
+	for _, p := range append(pPorts, cPorts...) {
+		if used[p] {
+			continue
+		}
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: p})
+		if assert.NoError(t, err, "port %d is not reclaimed", p) {
+			conn.Close()
+		}
+	}
+	assert.Equal(t, int64(portCount-2), provider.ReclaimedPorts())
+	assert.Equal(t, int64(portCount-2), consumer.ReclaimedPorts())
+}
+
+func TestPinger_ActivePorts(t *testing.T) {
+	pinger := newPinger(&PingConfig{
+		Interval: 5 * time.Millisecond,
+		Timeout:  300 * time.Millisecond,
+	}).(*Pinger)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	localPorts := []int{ports[0].Num(), ports[1].Num()}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pinger.PingConsumerPeer(context.Background(), "id", "127.0.0.1", localPorts, []int{ports[2].Num(), ports[3].Num()}, 2, 2)
+	}()
+
+	expected := append([]int{}, localPorts...)
+	sort.Ints(expected)
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(expected, pinger.ActivePorts())
+	}, time.Second, time.Millisecond)
+
+	<-done
+	assert.Empty(t, pinger.ActivePorts())
+}
+
 func TestPinger_PingPeer_Not_Enough_Connections_Timeout(t *testing.T) {
 	pingConfig := &PingConfig{
 		Interval: 10 * time.Millisecond,
@@ -101,11 +293,18 @@ func TestPinger_PingPeer_Not_Enough_Connections_Timeout(t *testing.T) {
 		consumerPingErr <- err
 	}()
 	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 30)
-	assert.Equal(t, ErrTooFew, err)
+	assert.ErrorIs(t, err, ErrTooFew)
 	assert.Len(t, conns, 0)
 
+	var pingErr *PingError
+	require.True(t, errors.As(err, &pingErr))
+	assert.Equal(t, 30, pingErr.Requested)
+	assert.Less(t, pingErr.Built, 30)
+	assert.Greater(t, pingErr.Sent, int64(0))
+	assert.Greater(t, pingErr.Received, int64(0))
+
 	consumerErr := <-consumerPingErr
-	assert.Equal(t, ErrTooFew, consumerErr)
+	assert.ErrorIs(t, consumerErr, ErrTooFew)
 }
 
 func TestPinger_PingConsumerPeer_Timeout(t *testing.T) {
@@ -130,7 +329,781 @@ func TestPinger_PingConsumerPeer_Timeout(t *testing.T) {
 
 	_, err = pinger.PingConsumerPeer(context.Background(), "id", "127.0.0.1", []int{consumerPort}, []int{providerPort}, 2, 2)
 
-	assert.Equal(t, ErrTooFew, err)
+	assert.ErrorIs(t, err, ErrTooFew)
+	var pingErr *PingError
+	require.True(t, errors.As(err, &pingErr))
+	assert.Equal(t, int64(0), pingErr.Received)
+}
+
+func TestPinger_PingConsumerPeer_FailsEarlyWhenUnreachable(t *testing.T) {
+	pinger := newPinger(&PingConfig{
+		Interval:         5 * time.Millisecond,
+		Timeout:          5 * time.Second,
+		UnreachableAfter: 3,
+	})
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+
+	// Peer ports are bound, but nothing ever replies.
+	var localPorts, remotePorts []int
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: ports[i].Num()})
+		require.NoError(t, err)
+		defer conn.Close()
+		remotePorts = append(remotePorts, ports[i].Num())
+		localPorts = append(localPorts, ports[2+i].Num())
+	}
+
+	start := time.Now()
+	_, err = pinger.PingConsumerPeer(context.Background(), "id", "127.0.0.1", localPorts, remotePorts, 2, 1)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, ErrLikelyUnreachable)
+	assert.ErrorIs(t, err, ErrTooFew)
+	var pingErr *PingError
+	require.True(t, errors.As(err, &pingErr))
+	assert.GreaterOrEqual(t, pingErr.Sent, int64(6))
+	assert.Equal(t, int64(0), pingErr.Received)
+}
+
+func TestPinger_MaxSendRate(t *testing.T) {
+	pinger := newPinger(&PingConfig{
+		Interval:    time.Millisecond,
+		Timeout:     300 * time.Millisecond,
+		MaxSendRate: 20,
+	})
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+
+	_, err = pinger.PingConsumerPeer(context.Background(), "id", "127.0.0.1", []int{ports[0].Num(), ports[1].Num()}, []int{ports[2].Num(), ports[3].Num()}, 2, 2)
+
+	var pingErr *PingError
+	require.True(t, errors.As(err, &pingErr))
+	assert.Greater(t, pingErr.Sent, int64(0))
+	assert.LessOrEqual(t, pingErr.Sent, int64(8))
+}
+
+func TestPinger_InitiatorDelay(t *testing.T) {
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	pPorts := []int{ports[0].Num(), ports[1].Num()}
+	cPorts := []int{ports[2].Num(), ports[3].Num()}
+
+	delayed := newPinger(&PingConfig{
+		Interval:       time.Millisecond,
+		Timeout:        100 * time.Millisecond,
+		InitiatorDelay: time.Hour,
+	})
+	_, err = delayed.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+	var pingErr *PingError
+	require.True(t, errors.As(err, &pingErr))
+	assert.Equal(t, int64(0), pingErr.Sent)
+
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+		InitiatorDelay:      20 * time.Millisecond,
+	}
+	provider := newPinger(pingConfig)
+	consumer := newPinger(pingConfig)
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 2)
+		assert.NoError(t, err)
+		closeConns(conns)
+	}()
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+	require.NoError(t, err)
+	assert.Len(t, conns, 2)
+	closeConns(conns)
+}
+
+func TestPinger_RemotePortHint(t *testing.T) {
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	pPorts := []int{ports[0].Num(), ports[1].Num()}
+	cPorts := []int{ports[2].Num(), ports[3].Num()}
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: cPorts[1]})
+	require.NoError(t, err)
+	defer peer.Close()
+
+	delayed := newPinger(&PingConfig{
+		Interval:       time.Millisecond,
+		Timeout:        100 * time.Millisecond,
+		InitiatorDelay: time.Hour,
+	})
+	ctx := WithRemotePortHint(context.Background(), cPorts[1])
+	_, err = delayed.PingConsumerPeer(ctx, "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+	assert.ErrorIs(t, err, ErrTooFew)
+
+	// Hinted port is pinged despite the initiator delay.
+	require.NoError(t, peer.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, bufferLen)
+	_, from, err := peer.ReadFromUDP(buf)
+	require.NoError(t, err)
+	assert.Equal(t, pPorts[1], from.Port)
+}
+
+type peerResolverFunc func(ctx context.Context, peer string) ([]net.IP, error)
+
+func (f peerResolverFunc) Resolve(ctx context.Context, peer string) ([]net.IP, error) {
+	return f(ctx, peer)
+}
+
+func TestPinger_PingPeer_ResolvesPeer(t *testing.T) {
+	resolver := peerResolverFunc(func(ctx context.Context, peer string) ([]net.IP, error) {
+		if peer != "peer-id" {
+			return nil, errors.New("unknown peer")
+		}
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	})
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+		PeerResolver:        resolver,
+	}
+	provider := newPinger(pingConfig)
+	consumer := newPinger(&PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	})
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(2)
+	require.NoError(t, err)
+	pPorts, cPorts := []int{ports[0].Num()}, []int{ports[1].Num()}
+
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 1)
+		assert.NoError(t, err)
+		closeConns(conns)
+	}()
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "peer-id", pPorts, cPorts, 2, 1)
+	require.NoError(t, err)
+	require.Len(t, conns, 1)
+	assert.Equal(t, "127.0.0.1", conns[0].RemoteAddr().(*net.UDPAddr).IP.String())
+	closeConns(conns)
+
+	_, err = provider.PingConsumerPeer(context.Background(), "id", "unknown", pPorts, cPorts, 2, 1)
+	assert.Error(t, err)
+}
+
+func TestPinger_PingConsumerPeerAtLeast_ReturnsPartialAfterDeadline(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	provider := newPinger(pingConfig).(*Pinger)
+	consumer := newPinger(pingConfig)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(6)
+	require.NoError(t, err)
+	// Only two of three port pairs are pinged by the consumer.
+	pPorts := []int{ports[0].Num(), ports[1].Num(), ports[2].Num()}
+	cPorts := []int{ports[3].Num(), ports[4].Num(), ports[5].Num()}
+
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts[:2], pPorts[:2], 128, 2)
+		assert.NoError(t, err)
+		closeConns(conns)
+	}()
+	conns, err := provider.PingConsumerPeerAtLeast(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 3, time.Second)
+	defer closeConns(conns)
+
+	assert.ErrorIs(t, err, ErrPartial)
+	assert.Len(t, conns, 2)
+}
+
+func TestPinger_PartialPingResult_RecordsFailure(t *testing.T) {
+	publisher := &recordingPublisher{}
+	pinger := NewPinger(&PingConfig{ReuseWindow: time.Minute}, publisher).(*Pinger)
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conns, err := pinger.consumerPartialPingResult(context.Background(), "id", "127.0.0.1", []*net.UDPConn{conn}, 2, &pingCounters{})
+
+	assert.ErrorIs(t, err, ErrPartial)
+	assert.Len(t, conns, 1)
+	require.Len(t, publisher.events, 1)
+	assert.False(t, publisher.events[0].Successful)
+	assert.ErrorIs(t, publisher.events[0].Error, ErrPartial)
+	assert.Less(t, pinger.history.estimate("127.0.0.1"), 0.5)
+	assert.NotContains(t, pinger.mappings, "127.0.0.1")
+}
+
+func TestPinger_PingConsumerPeerWithDeadline_ReturnsPartial(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+		RetryBackoff:        10 * time.Millisecond,
+	}
+	provider := newPinger(pingConfig).(*Pinger)
+	consumer := newPinger(pingConfig)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(6)
+	require.NoError(t, err)
+	// Only two of three port pairs are pinged by the consumer.
+	pPorts := []int{ports[0].Num(), ports[1].Num(), ports[2].Num()}
+	cPorts := []int{ports[3].Num(), ports[4].Num(), ports[5].Num()}
+
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts[:2], pPorts[:2], 128, 2)
+		assert.NoError(t, err)
+		closeConns(conns)
+	}()
+	start := time.Now()
+	conns, err := provider.PingConsumerPeerWithDeadline(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 3, start.Add(time.Second))
+	defer closeConns(conns)
+
+	assert.ErrorIs(t, err, ErrPartial)
+	assert.Len(t, conns, 2)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestPinger_PingProviderPeerAtLeast_ReturnsOnceMinIsBuilt(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	provider := newPinger(pingConfig)
+	consumer := newPinger(pingConfig).(*Pinger)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	pPorts := []int{ports[0].Num(), ports[1].Num()}
+	cPorts := []int{ports[2].Num(), ports[3].Num()}
+
+	go func() {
+		conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 1)
+		assert.NoError(t, err)
+		closeConns(conns)
+	}()
+	conns, err := consumer.PingProviderPeerAtLeast(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 1, time.Second)
+	defer closeConns(conns)
+
+	assert.NoError(t, err)
+	assert.Len(t, conns, 1)
+}
+
+func TestPinger_PingResolved_TriesAddressesInOrder(t *testing.T) {
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{
+		PeerResolver: peerResolverFunc(func(ctx context.Context, peer string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")}, nil
+		}),
+	}}
+
+	var tried []string
+	_, err := pinger.pingResolved(context.Background(), "peer-id", func(remoteIP string, _ *pingCounters) ([]*net.UDPConn, error) {
+		tried = append(tried, remoteIP)
+		if remoteIP == "10.0.0.2" {
+			return nil, nil
+		}
+		return nil, ErrTooFew
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, tried)
+}
+
+func TestPinger_PingResolved_TriesHintedAddressesFirst(t *testing.T) {
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{
+		PeerResolver: peerResolverFunc(func(ctx context.Context, peer string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3"), net.ParseIP("10.0.0.4")}, nil
+		}),
+	}}
+
+	ctx := WithLatencyHints(context.Background(), map[string]time.Duration{
+		"10.0.0.3": 50 * time.Millisecond,
+		"10.0.0.4": 10 * time.Millisecond,
+	})
+	var tried []string
+	_, err := pinger.pingResolved(ctx, "peer-id", func(remoteIP string, _ *pingCounters) ([]*net.UDPConn, error) {
+		tried = append(tried, remoteIP)
+		return nil, ErrTooFew
+	})
+	assert.ErrorIs(t, err, ErrTooFew)
+	assert.Equal(t, []string{"10.0.0.4", "10.0.0.3", "10.0.0.1", "10.0.0.2"}, tried)
+}
+
+func TestPinger_PingConsumerPeer_PublishesServiceType(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	publisher := &recordingPublisher{}
+	provider := NewPinger(pingConfig, publisher)
+	consumer := newPinger(pingConfig)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	pPorts := []int{ports[0].Num(), ports[1].Num()}
+	cPorts := []int{ports[2].Num(), ports[3].Num()}
+
+	go func() {
+		conns, err := consumer.PingProviderPeer(WithServiceType(context.Background(), "wireguard"), "", "127.0.0.1", cPorts, pPorts, 128, 2)
+		assert.NoError(t, err)
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	conns, err := provider.PingConsumerPeer(WithServiceType(context.Background(), "wireguard"), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+	require.NoError(t, err)
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	require.Len(t, publisher.events, 1)
+	ev := publisher.events[0]
+	assert.Equal(t, "id", ev.ID)
+	assert.Equal(t, StageName, ev.Stage)
+	assert.Equal(t, "wireguard", ev.ServiceType)
+	assert.True(t, ev.Successful)
+}
+
+func TestDetach_KeepsLabelsButNotCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(WithServiceType(context.Background(), "wireguard"), time.Minute)
+	detached := Detach(ctx)
+	cancel()
+
+	assert.NoError(t, detached.Err())
+	_, ok := detached.Deadline()
+	assert.False(t, ok)
+	assert.Equal(t, "wireguard", serviceTypeFromContext(detached))
+}
+
+func TestPinger_PingConsumerPeer_PublishesPhaseDurations(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}
+	publisher := &recordingPublisher{}
+	provider := NewPinger(pingConfig, publisher)
+	consumer := newPinger(pingConfig)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	pPorts := []int{ports[0].Num(), ports[1].Num()}
+	cPorts := []int{ports[2].Num(), ports[3].Num()}
+
+	go func() {
+		conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 2)
+		assert.NoError(t, err)
+		closeConns(conns)
+	}()
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+	require.NoError(t, err)
+	closeConns(conns)
+
+	require.Len(t, publisher.events, 1)
+	phases := publisher.events[0].Phases
+	assert.Contains(t, phases, phaseSocketSetup)
+	assert.Contains(t, phases, phasePunch)
+	assert.Contains(t, phases, phaseConfirm)
+	assert.NotContains(t, phases, phaseResolve)
+}
+
+func TestPinger_PingConsumerPeer_DetectsDoubleNAT(t *testing.T) {
+	publisher := &recordingPublisher{}
+	provider := NewPinger(&PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}, publisher)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(2)
+	require.NoError(t, err)
+	providerPort, candidatePort := ports[0].Num(), ports[1].Num()
+
+	// Peer is announced on the candidate port, but its pings arrive from another one.
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+	require.NotEqual(t, candidatePort, peer.LocalAddr().(*net.UDPAddr).Port)
+
+	go func() {
+		providerAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: providerPort}
+		buf := make([]byte, bufferLen)
+		for {
+			if _, err := peer.WriteToUDP([]byte(msgPing+providerAddr.String()), providerAddr); err != nil {
+				return
+			}
+			peer.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+			n, _, err := peer.ReadFromUDP(buf)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if string(buf[:n]) == msgOK {
+				peer.WriteToUDP([]byte(msgOKACK), providerAddr)
+			}
+		}
+	}()
+
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", []int{providerPort}, []int{candidatePort}, 2, 1)
+	require.NoError(t, err)
+	closeConns(conns)
+
+	require.Len(t, publisher.events, 1)
+	assert.True(t, publisher.events[0].Successful)
+	assert.True(t, publisher.events[0].DoubleNAT)
+}
+
+func TestPinger_PingConsumerPeer_ReplyFromOtherCandidateIsNotDoubleNAT(t *testing.T) {
+	publisher := &recordingPublisher{}
+	provider := NewPinger(&PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+	}, publisher)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(4)
+	require.NoError(t, err)
+	pPorts := []int{ports[0].Num(), ports[1].Num()}
+	candidates := []int{ports[2].Num(), ports[3].Num()}
+
+	// Peer answers the first provider port from the second candidate port.
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: candidates[1]})
+	require.NoError(t, err)
+	defer peer.Close()
+
+	go func() {
+		providerAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: pPorts[0]}
+		buf := make([]byte, bufferLen)
+		for {
+			if _, err := peer.WriteToUDP([]byte(msgPing+providerAddr.String()), providerAddr); err != nil {
+				return
+			}
+			peer.SetReadDeadline(time.Now().Add(5 * time.Millisecond))
+			n, addr, err := peer.ReadFromUDP(buf)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if string(buf[:n]) == msgOK && addr.Port == providerAddr.Port {
+				peer.WriteToUDP([]byte(msgOKACK), providerAddr)
+			}
+		}
+	}()
+
+	conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, candidates, 2, 1)
+	require.NoError(t, err)
+	closeConns(conns)
+
+	require.Len(t, publisher.events, 1)
+	assert.True(t, publisher.events[0].Successful)
+	assert.False(t, publisher.events[0].DoubleNAT)
+}
+
+func TestPinger_PingPeer_ReusesMapping(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             5 * time.Second,
+		ReuseWindow:         time.Minute,
+		ReuseProbeTimeout:   time.Second,
+	}
+	provider := newPinger(pingConfig)
+	consumer := newPinger(pingConfig)
+
+	ping := func(pPorts, cPorts []int) (pConns, cConns []*net.UDPConn) {
+		consumerConns := make(chan []*net.UDPConn, 1)
+		go func() {
+			conns, err := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts, pPorts, 128, 2)
+			assert.NoError(t, err)
+			consumerConns <- conns
+		}()
+		conns, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+		require.NoError(t, err)
+		return conns, <-consumerConns
+	}
+	localPorts := func(conns []*net.UDPConn) (ports []int) {
+		for _, conn := range conns {
+			ports = append(ports, conn.LocalAddr().(*net.UDPAddr).Port)
+			conn.Close()
+		}
+		return ports
+	}
+
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(portCount * 2)
+	require.NoError(t, err)
+	var pPorts, cPorts []int
+	for i := 0; i < portCount; i++ {
+		pPorts = append(pPorts, ports[i].Num())
+		cPorts = append(cPorts, ports[portCount+i].Num())
+	}
+	pConns, cConns := ping(pPorts, cPorts)
+	require.Len(t, pConns, 2)
+	require.Len(t, cConns, 2)
+	firstProviderPorts, firstConsumerPorts := localPorts(pConns), localPorts(cConns)
+
+	// Peers negotiated the same ports again, so only the remembered pairs are probed.
+	pConns, cConns = ping(pPorts, cPorts)
+	require.Len(t, pConns, 2)
+	require.Len(t, cConns, 2)
+	assert.Equal(t, firstProviderPorts, localPorts(pConns))
+	assert.Equal(t, firstConsumerPorts, localPorts(cConns))
+}
+
+func TestPinger_ReuseMapping_RequiresNegotiatedPorts(t *testing.T) {
+	pinger := NewPinger(&PingConfig{ReuseWindow: time.Minute, Timeout: time.Second}, &mockPublisher{}).(*Pinger)
+	pinger.mappings["10.0.0.1"] = peerMapping{
+		localPorts:  []int{1001, 1002},
+		remotePorts: []int{2001, 2002},
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	var probed [][]int
+	ping := func(ctx context.Context, localPorts, remotePorts []int) ([]*net.UDPConn, error) {
+		probed = append(probed, localPorts, remotePorts)
+		return nil, nil
+	}
+
+	_, err := pinger.reuseMapping(context.Background(), "10.0.0.1", []int{1001, 1003}, []int{2001, 2003}, 2, ping)
+	assert.ErrorIs(t, err, errNoMapping)
+	_, err = pinger.reuseMapping(context.Background(), "10.0.0.1", []int{1002, 1001}, []int{2001, 2002}, 2, ping)
+	assert.ErrorIs(t, err, errNoMapping)
+	assert.Empty(t, probed)
+
+	_, err = pinger.reuseMapping(context.Background(), "10.0.0.1", []int{1003, 1002, 1001}, []int{2003, 2002, 2001}, 2, ping)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{1001, 1002}, {2001, 2002}}, probed)
+}
+
+func TestPinger_PingWithRetry_KeepsBuiltConnections(t *testing.T) {
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{
+		Timeout:         time.Second,
+		RetryBackoff:    time.Millisecond,
+		RetryMaxBackoff: 2 * time.Millisecond,
+	}}
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(3)
+	require.NoError(t, err)
+	localPorts := []int{ports[0].Num(), ports[1].Num(), ports[2].Num()}
+	remotePorts := []int{1001, 1002, 1003}
+
+	type attempt struct {
+		localPorts, remotePorts []int
+		n                       int
+	}
+	var attempts []attempt
+	ping := func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error) {
+		attempts = append(attempts, attempt{localPorts: localPorts, remotePorts: remotePorts, n: n})
+		// Every attempt manages to build a single connection on the first port pair.
+		conn, err := net.DialUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: localPorts[0]}, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: remotePorts[0]})
+		require.NoError(t, err)
+		if n > 1 {
+			return []*net.UDPConn{conn}, ErrTooFew
+		}
+		return []*net.UDPConn{conn}, nil
+	}
+
+	conns, err := pinger.pingWithRetry(context.Background(), localPorts, remotePorts, 2, ping)
+	require.NoError(t, err)
+	require.Len(t, conns, 2)
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	assert.Equal(t, []attempt{
+		{localPorts: localPorts, remotePorts: remotePorts, n: 2},
+		{localPorts: localPorts[1:], remotePorts: remotePorts[1:], n: 1},
+	}, attempts)
+}
+
+func TestPinger_PingWithRetry_GivesUpWhenPortsRunOut(t *testing.T) {
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{Timeout: time.Second}}
+	ping := func(ctx context.Context, localPorts, remotePorts []int, n int) ([]*net.UDPConn, error) {
+		return nil, ErrTooFew
+	}
+
+	_, err := pinger.pingWithRetry(context.Background(), []int{1, 2}, []int{3, 4}, 3, ping)
+	assert.ErrorIs(t, err, ErrTooFew)
+}
+
+func TestPinger_PingConsumerPeer_ScopesUnreachablePerAttempt(t *testing.T) {
+	pingConfig := &PingConfig{
+		Interval:            5 * time.Millisecond,
+		SendConnACKInterval: 5 * time.Millisecond,
+		Timeout:             300 * time.Millisecond,
+		ReuseWindow:         time.Minute,
+		ReuseProbeTimeout:   time.Second,
+		RetryBackoff:        time.Millisecond,
+		UnreachableAfter:    3,
+	}
+	// setup returns port pairs of which only the first one has a live peer,
+	// the peer ports of the rest are bound, but nothing ever replies.
+	setup := func(t *testing.T, pairs int) (pPorts, cPorts []int) {
+		ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(2 * pairs)
+		require.NoError(t, err)
+		for i := 0; i < pairs; i++ {
+			pPorts = append(pPorts, ports[i].Num())
+			cPorts = append(cPorts, ports[pairs+i].Num())
+		}
+		for _, p := range cPorts[1:] {
+			conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: p})
+			require.NoError(t, err)
+			t.Cleanup(func() { conn.Close() })
+		}
+		consumer := newPinger(&PingConfig{
+			Interval:            5 * time.Millisecond,
+			SendConnACKInterval: 5 * time.Millisecond,
+			Timeout:             5 * time.Second,
+		})
+		go func() {
+			conns, _ := consumer.PingProviderPeer(context.Background(), "", "127.0.0.1", cPorts[:1], pPorts[:1], 128, 1)
+			closeConns(conns)
+		}()
+		return pPorts, cPorts
+	}
+
+	t.Run("failed reuse probe", func(t *testing.T) {
+		pPorts, cPorts := setup(t, 3)
+		provider := NewPinger(pingConfig, &mockPublisher{}).(*Pinger)
+		provider.mappings["127.0.0.1"] = peerMapping{
+			localPorts:  pPorts[1:],
+			remotePorts: cPorts[1:],
+			expiresAt:   time.Now().Add(time.Minute),
+		}
+
+		_, err := provider.PingConsumerPeer(context.Background(), "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+
+		var pingErr *PingError
+		require.True(t, errors.As(err, &pingErr))
+		assert.Equal(t, 1, pingErr.Built)
+		assert.NotErrorIs(t, err, ErrLikelyUnreachable, "peer replied during the full ping")
+	})
+	t.Run("retry", func(t *testing.T) {
+		pPorts, cPorts := setup(t, 2)
+		provider := NewPinger(pingConfig, &mockPublisher{}).(*Pinger)
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		_, err := provider.PingConsumerPeerWithRetry(ctx, "id", "127.0.0.1", pPorts, cPorts, 2, 2)
+
+		assert.ErrorIs(t, err, ErrLikelyUnreachable, "retry does not count replies of the previous attempt")
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
+
+func TestPinger_ListenUDP_ReusePort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported on windows")
+	}
+
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{ReusePort: true}}
+	conn1, err := pinger.listenUDP(&net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn1.Close()
+
+	conn2, err := pinger.listenUDP(conn1.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	pinger.pingConfig.ReusePort = false
+	_, err = pinger.listenUDP(conn1.LocalAddr().(*net.UDPAddr))
+	assert.Error(t, err)
+}
+
+func TestPinger_ListenUDP_DontFragment(t *testing.T) {
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{DontFragment: true}}
+	conn, err := pinger.listenUDP(&net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.ErrorIs(t, sendError(&net.OpError{Op: "write", Err: syscall.EMSGSIZE}), ErrMessageSize)
+	assert.NotErrorIs(t, sendError(syscall.ECONNREFUSED), ErrMessageSize)
+}
+
+func TestPinger_Interval_ClampedToMinimum(t *testing.T) {
+	pinger := newPinger(&PingConfig{Interval: time.Millisecond, MinInterval: 5 * time.Millisecond}).(*Pinger)
+	assert.Equal(t, 5*time.Millisecond, pinger.interval())
+
+	pinger = newPinger(&PingConfig{Interval: 10 * time.Millisecond, MinInterval: 5 * time.Millisecond}).(*Pinger)
+	assert.Equal(t, 10*time.Millisecond, pinger.interval())
+
+	pinger = newPinger(&PingConfig{Interval: time.Millisecond}).(*Pinger)
+	assert.Equal(t, time.Millisecond, pinger.interval())
+}
+
+func TestPinger_Healthy(t *testing.T) {
+	pinger := newPinger(DefaultPingConfig()).(*Pinger)
+
+	assert.NoError(t, pinger.Healthy(port.NewFixedRangePool(port.Range{Start: 10000, End: 60000})))
+
+	err := pinger.Healthy(portSupplierFunc(func() (port.Port, error) {
+		return 0, errors.New("port pool is exhausted")
+	}))
+	assert.ErrorContains(t, err, "port pool is exhausted")
+
+	taken, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	require.NoError(t, err)
+	defer taken.Close()
+	err = pinger.Healthy(portSupplierFunc(func() (port.Port, error) {
+		return port.Port(taken.LocalAddr().(*net.UDPAddr).Port), nil
+	}))
+	assert.ErrorContains(t, err, "failed to bind UDP socket")
+}
+
+func TestPinger_Healthy_ReleasesPort(t *testing.T) {
+	pinger := newPinger(DefaultPingConfig()).(*Pinger)
+	pool := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000})
+	supplier := &releasingPortSupplier{pool: pool}
+
+	require.NoError(t, pinger.Healthy(supplier))
+	require.NoError(t, pinger.Healthy(supplier))
+
+	assert.Len(t, supplier.released, 2)
+	assert.Equal(t, supplier.acquired, supplier.released)
+}
+
+type releasingPortSupplier struct {
+	pool     *port.Pool
+	acquired []port.Port
+	released []port.Port
+}
+
+func (s *releasingPortSupplier) Acquire() (port.Port, error) {
+	p, err := s.pool.Acquire()
+	s.acquired = append(s.acquired, p)
+	return p, err
+}
+
+func (s *releasingPortSupplier) Release(ports ...port.Port) {
+	s.pool.Release(ports...)
+	s.released = append(s.released, ports...)
+}
+
+type portSupplierFunc func() (port.Port, error)
+
+func (f portSupplierFunc) Acquire() (port.Port, error) {
+	return f()
+}
+
+func TestPinger_WaitMsg_PeekLiveness(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("MSG_PEEK is not used on windows")
+	}
+
+	pinger := &Pinger{clock: realClock{}, pingConfig: &PingConfig{
+		Interval:     time.Millisecond,
+		Timeout:      time.Second,
+		PeekLiveness: true,
+	}}
+
+	conn1, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.NoError(t, err)
+	defer conn1.Close()
+	conn2, err := net.DialUDP("udp4", nil, conn1.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	_, err = conn2.Write([]byte(msgPing + "127.0.0.1"))
+	require.NoError(t, err)
+	_, err = conn2.Write([]byte("service data"))
+	require.NoError(t, err)
+
+	err = pinger.waitMsg(context.Background(), conn1, msgOK)
+	assert.NoError(t, err)
+
+	buf := make([]byte, bufferLen)
+	n, err := conn1.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "service data", string(buf[:n]))
 }
 
 func newPinger(config *PingConfig) NATPinger {
@@ -141,3 +1114,13 @@ type mockPublisher struct{}
 
 func (p mockPublisher) Publish(topic string, data interface{}) {
 }
+
+type recordingPublisher struct {
+	events []event.Event
+}
+
+func (p *recordingPublisher) Publish(topic string, data interface{}) {
+	if e, ok := data.(event.Event); ok {
+		p.events = append(p.events, e)
+	}
+}
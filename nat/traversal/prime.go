@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/mysteriumnetwork/node/router"
+)
+
+// primePackets is the number of pings sent to open the local NAT mapping.
+const primePackets = 3
+
+// PrimeMapping sends a few pings from the local port to the peer to open the mapping
+// of the local NAT, so the first packet of a directly reachable peer gets through.
+// It does not wait for a reply.
+func (p *Pinger) PrimeMapping(ctx context.Context, peerIP string, localPort, remotePort int) error {
+	defer p.holdPorts([]int{localPort})()
+
+	conn, err := p.listenUDP(&net.UDPAddr{Port: localPort})
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := router.ProtectUDPConn(conn); err != nil {
+		return fmt.Errorf("failed to protect udp connection: %w", err)
+	}
+
+	remoteAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", peerIP, remotePort))
+	if err != nil {
+		return fmt.Errorf("failed to resolve remote address: %w", err)
+	}
+
+	for i := 0; i < primePackets; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-p.clock.After(p.interval()):
+			}
+		}
+		if p.limiter != nil {
+			if err := p.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		msg := []byte(msgPing + remoteAddr.String())
+		if _, err := conn.WriteToUDP(msg, remoteAddr); err != nil {
+			return fmt.Errorf("priming request failed: %w", sendError(err))
+		}
+		p.tap(PacketSent, msg, remoteAddr)
+	}
+
+	log.Debug().Msgf("Primed NAT mapping %s -> %s", conn.LocalAddr(), remoteAddr)
+	return nil
+}
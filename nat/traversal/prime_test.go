@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mysteriumnetwork/node/core/port"
+)
+
+func TestPinger_PrimeMapping(t *testing.T) {
+	pinger := newPinger(&PingConfig{Interval: time.Millisecond, Timeout: time.Second}).(*Pinger)
+	ports, err := port.NewFixedRangePool(port.Range{Start: 10000, End: 60000}).AcquireMultiple(1)
+	require.NoError(t, err)
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+
+	err = pinger.PrimeMapping(context.Background(), "127.0.0.1", ports[0].Num(), peer.LocalAddr().(*net.UDPAddr).Port)
+	require.NoError(t, err)
+
+	buf := make([]byte, bufferLen)
+	for i := 0; i < primePackets; i++ {
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(time.Second)))
+		n, from, err := peer.ReadFromUDP(buf)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(buf[:n]), msgPing))
+		assert.Equal(t, ports[0].Num(), from.Port)
+	}
+	assert.Empty(t, pinger.ActivePorts())
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPeerQuarantined indicates pinging was skipped, because recent attempts to
+// reach the peer failed repeatedly and the cooldown has not passed yet.
+var ErrPeerQuarantined = errors.New("peer is quarantined after repeated failures")
+
+// quarantine tracks consecutive punch failures per remote peer.
+type quarantine struct {
+	mu        sync.Mutex
+	failures  map[string]failureStreak
+	until     map[string]time.Time
+	lastSweep time.Time
+}
+
+type failureStreak struct {
+	count int
+	last  time.Time
+}
+
+// failed records a failure and quarantines the peer until now+cooldown
+// after threshold consecutive failures.
+func (q *quarantine) failed(remoteIP string, threshold int, now time.Time, cooldown time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.failures == nil {
+		q.failures = make(map[string]failureStreak)
+		q.until = make(map[string]time.Time)
+	}
+	q.sweep(now, cooldown)
+
+	streak := q.failures[remoteIP]
+	streak.count++
+	streak.last = now
+	if streak.count >= threshold {
+		q.until[remoteIP] = now.Add(cooldown)
+		delete(q.failures, remoteIP)
+		return
+	}
+	q.failures[remoteIP] = streak
+}
+
+// sweep drops expired quarantines and failure streaks of peers which did not
+// fail for longer than the cooldown, so peers which are not pinged anymore are
+// not tracked forever. It runs at most once per cooldown.
+func (q *quarantine) sweep(now time.Time, cooldown time.Duration) {
+	if now.Sub(q.lastSweep) < cooldown {
+		return
+	}
+	q.lastSweep = now
+
+	for remoteIP, until := range q.until {
+		if !now.Before(until) {
+			delete(q.until, remoteIP)
+		}
+	}
+	for remoteIP, streak := range q.failures {
+		if now.Sub(streak.last) > cooldown {
+			delete(q.failures, remoteIP)
+		}
+	}
+}
+
+// succeeded clears failures and quarantine of the peer.
+func (q *quarantine) succeeded(remoteIP string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.failures, remoteIP)
+	delete(q.until, remoteIP)
+}
+
+func (q *quarantine) quarantined(remoteIP string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	until, ok := q.until[remoteIP]
+	if ok && !now.Before(until) {
+		delete(q.until, remoteIP)
+		return false
+	}
+	return ok
+}
+
+// recordOutcome records the outcome of punching to the peer.
+func (p *Pinger) recordOutcome(remoteIP string, success bool) {
+	p.history.add(remoteIP, success)
+	if p.pingConfig.QuarantineThreshold <= 0 {
+		return
+	}
+
+	if success {
+		p.quarantine.succeeded(remoteIP)
+	} else {
+		p.quarantine.failed(remoteIP, p.pingConfig.QuarantineThreshold, p.clock.Now(), p.pingConfig.QuarantineCooldown)
+	}
+}
+
+func (p *Pinger) checkQuarantine(remoteIP string) error {
+	if p.pingConfig.QuarantineThreshold > 0 && p.quarantine.quarantined(remoteIP, p.clock.Now()) {
+		return ErrPeerQuarantined
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinger_QuarantinesRepeatedlyFailingPeer(t *testing.T) {
+	clock := newMockClock()
+	pinger := newPinger(&PingConfig{
+		Interval:            time.Millisecond,
+		Timeout:             time.Second,
+		QuarantineThreshold: 2,
+		QuarantineCooldown:  time.Minute,
+	}).(*Pinger)
+	pinger.clock = clock
+
+	pinger.recordOutcome("10.0.0.1", false)
+	assert.NoError(t, pinger.checkQuarantine("10.0.0.1"))
+	pinger.recordOutcome("10.0.0.1", false)
+
+	_, err := pinger.PingProviderPeer(context.Background(), "", "10.0.0.1", []int{1}, []int{2}, 128, 1)
+	assert.ErrorIs(t, err, ErrPeerQuarantined)
+	_, err = pinger.PingConsumerPeer(context.Background(), "id", "10.0.0.1", []int{1}, []int{2}, 2, 1)
+	assert.ErrorIs(t, err, ErrPeerQuarantined)
+	assert.NoError(t, pinger.checkQuarantine("10.0.0.2"))
+
+	clock.Advance(time.Minute)
+	assert.NoError(t, pinger.checkQuarantine("10.0.0.1"))
+}
+
+func TestQuarantine_SweepsExpiredEntries(t *testing.T) {
+	var q quarantine
+	now := time.Now()
+	q.failed("10.0.0.1", 1, now, time.Minute)
+	q.failed("10.0.0.2", 2, now, time.Minute)
+	assert.Len(t, q.until, 1)
+	assert.Len(t, q.failures, 1)
+
+	q.failed("10.0.0.3", 2, now.Add(2*time.Minute), time.Minute)
+
+	assert.Empty(t, q.until)
+	assert.Len(t, q.failures, 1)
+	assert.Contains(t, q.failures, "10.0.0.3")
+}
+
+func TestPinger_SuccessClearsQuarantine(t *testing.T) {
+	pinger := newPinger(&PingConfig{
+		QuarantineThreshold: 2,
+		QuarantineCooldown:  time.Minute,
+	}).(*Pinger)
+
+	pinger.recordOutcome("10.0.0.1", false)
+	pinger.recordOutcome("10.0.0.1", false)
+	assert.ErrorIs(t, pinger.checkQuarantine("10.0.0.1"), ErrPeerQuarantined)
+
+	pinger.recordOutcome("10.0.0.1", true)
+	assert.NoError(t, pinger.checkQuarantine("10.0.0.1"))
+
+	// Failures are counted from the last success.
+	pinger.recordOutcome("10.0.0.1", false)
+	assert.NoError(t, pinger.checkQuarantine("10.0.0.1"))
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PeerResolver resolves a peer identifier, e.g. a hostname or an id from
+// a directory, to candidate IP addresses. Ports are agreed on separately.
+type PeerResolver interface {
+	Resolve(ctx context.Context, peer string) ([]net.IP, error)
+}
+
+// pingResolved resolves peer and pings its addresses one by one until one succeeds
+// or returns some connections.
+func (p *Pinger) pingResolved(ctx context.Context, peer string, ping func(remoteIP string, counters *pingCounters) ([]*net.UDPConn, error)) ([]*net.UDPConn, error) {
+	if p.pingConfig.PeerResolver == nil {
+		if err := p.checkQuarantine(peer); err != nil {
+			return nil, err
+		}
+		return ping(peer, &pingCounters{})
+	}
+
+	start := p.clock.Now()
+	ips, err := p.pingConfig.PeerResolver.Resolve(ctx, peer)
+	resolveDuration := p.clock.Now().Sub(start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve peer %s: %w", peer, err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("peer %s resolved to no addresses", peer)
+	}
+
+	ips = orderByLatency(ips, latencyHintsFromContext(ctx))
+
+	var conns []*net.UDPConn
+	for _, ip := range ips {
+		if err = p.checkQuarantine(ip.String()); err != nil {
+			log.Debug().Err(err).Msgf("Skipping peer %s at %s", peer, ip)
+			continue
+		}
+
+		counters := &pingCounters{}
+		counters.observeLongest(phaseResolve, resolveDuration)
+		conns, err = ping(ip.String(), counters)
+		if err == nil || len(conns) > 0 || ctx.Err() != nil {
+			return conns, err
+		}
+		log.Debug().Err(err).Msgf("Failed to ping peer %s at %s", peer, ip)
+	}
+	return nil, err
+}
+
+// orderByLatency moves candidates with a latency hint to the front, closest
+// first, keeping the order of the rest. Resolver's slice is left untouched.
+func orderByLatency(ips []net.IP, hints map[string]time.Duration) []net.IP {
+	if len(hints) == 0 {
+		return ips
+	}
+
+	ips = append([]net.IP(nil), ips...)
+	sort.SliceStable(ips, func(i, j int) bool {
+		li, iok := hints[ips[i].String()]
+		lj, jok := hints[ips[j].String()]
+		if iok != jok {
+			return iok
+		}
+		return iok && li < lj
+	})
+	return ips
+}
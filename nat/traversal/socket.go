@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errReusePortUnsupported    = errors.New("SO_REUSEPORT is not supported on this platform")
+	errDontFragmentUnsupported = errors.New("DF bit is not supported on this platform")
+	errPeekUnsupported         = errors.New("MSG_PEEK is not supported on this platform")
+)
+
+func (p *Pinger) listenUDP(laddr *net.UDPAddr) (*net.UDPConn, error) {
+	if !p.pingConfig.ReusePort && !p.pingConfig.DontFragment {
+		return net.ListenUDP("udp4", laddr)
+	}
+
+	lc := net.ListenConfig{Control: p.controlSocket}
+	conn, err := lc.ListenPacket(context.Background(), "udp4", laddr.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+func (p *Pinger) dialUDP(laddr, raddr *net.UDPAddr) (*net.UDPConn, error) {
+	if !p.pingConfig.ReusePort && !p.pingConfig.DontFragment {
+		return net.DialUDP("udp4", laddr, raddr)
+	}
+
+	d := net.Dialer{LocalAddr: laddr, Control: p.controlSocket}
+	conn, err := d.Dial("udp4", raddr.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// controlSocket sets socket options enabled in the ping config.
+func (p *Pinger) controlSocket(network, address string, c syscall.RawConn) error {
+	if p.pingConfig.ReusePort {
+		if err := controlOption(c, address, setReusePort, errReusePortUnsupported); err != nil {
+			return err
+		}
+	}
+	if p.pingConfig.DontFragment {
+		if err := controlOption(c, address, setDontFragment, errDontFragmentUnsupported); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func controlOption(c syscall.RawConn, address string, set func(fd uintptr) error, errUnsupported error) error {
+	var sockErr error
+	if err := c.Control(func(fd uintptr) {
+		sockErr = set(fd)
+	}); err != nil {
+		return err
+	}
+
+	if errors.Is(sockErr, errUnsupported) {
+		log.Debug().Msgf("%v, binding %s without it", sockErr, address)
+		return nil
+	}
+	return sockErr
+}
+
+// sendError tells apart sends failed because of the path MTU.
+func sendError(err error) error {
+	if errors.Is(err, syscall.EMSGSIZE) {
+		return fmt.Errorf("%w: %v", ErrMessageSize, err)
+	}
+	return err
+}
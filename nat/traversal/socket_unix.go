@@ -0,0 +1,50 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+const peekSupported = true
+
+func setReusePort(fd uintptr) error {
+	return unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+}
+
+// peekUDP reads the next datagram without removing it from the socket buffer.
+func peekUDP(conn *net.UDPConn, buf []byte) (n int, err error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var recvErr error
+	err = rc.Read(func(fd uintptr) bool {
+		n, _, recvErr = unix.Recvfrom(int(fd), buf, unix.MSG_PEEK)
+		return recvErr != unix.EAGAIN && recvErr != unix.EWOULDBLOCK
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, recvErr
+}
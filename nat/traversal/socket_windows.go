@@ -0,0 +1,33 @@
+//go:build windows
+
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import "net"
+
+// peekSupported is false, since MSG_PEEK is not supported for overlapped sockets on windows.
+const peekSupported = false
+
+func setReusePort(fd uintptr) error {
+	return errReusePortUnsupported
+}
+
+func peekUDP(conn *net.UDPConn, buf []byte) (n int, err error) {
+	return 0, errPeekUnsupported
+}
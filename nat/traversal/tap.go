@@ -0,0 +1,50 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversal
+
+import "net"
+
+// PacketDirection tells whether a tapped packet was sent or received.
+type PacketDirection int
+
+const (
+	// PacketSent is a packet sent to the peer.
+	PacketSent PacketDirection = iota
+	// PacketReceived is a packet received from the peer.
+	PacketReceived
+)
+
+// String returns the direction name.
+func (d PacketDirection) String() string {
+	if d == PacketSent {
+		return "sent"
+	}
+	return "received"
+}
+
+// PacketTap observes packets exchanged with the peer while punching.
+// It is called synchronously from the pinging goroutines, so it must be fast
+// and must not retain data after returning.
+type PacketTap func(dir PacketDirection, data []byte, peer net.Addr)
+
+func (p *Pinger) tap(dir PacketDirection, data []byte, peer net.Addr) {
+	if p.pingConfig.Tap == nil {
+		return
+	}
+	p.pingConfig.Tap(dir, data, peer)
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package traversaltest contains an in-process NAT simulator for exercising
+// NAT traversal against different NAT types without real routers.
+//
+// All simulated NATs live on the loopback interface. Every NAT owns a set of
+// external UDP sockets on 127.0.0.1 and relays traffic between them and the
+// local ports of the host behind it. Since everything shares one IP address,
+// a NAT is identified by the simulator itself rather than by its address,
+// which keeps restricted cone filtering meaningful on loopback.
+//
+// Hosts behind a simulated NAT must only talk to external ports of other
+// simulated NATs, traffic sent anywhere else is not translated.
+package traversaltest
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// Type is a NAT mapping and filtering behaviour.
+type Type int
+
+const (
+	// FullCone maps a local port to a single external port and accepts
+	// traffic on it from anyone.
+	FullCone Type = iota
+	// Restricted maps a local port to a single external port and accepts
+	// traffic only from hosts the local port has sent to.
+	Restricted
+	// PortRestricted maps a local port to a single external port and accepts
+	// traffic only from addresses the local port has sent to.
+	PortRestricted
+	// Symmetric maps every destination of a local port to its own external
+	// port and accepts traffic only from that destination.
+	Symmetric
+)
+
+// String returns the name of the NAT type.
+func (t Type) String() string {
+	switch t {
+	case FullCone:
+		return "full-cone"
+	case Restricted:
+		return "restricted"
+	case PortRestricted:
+		return "port-restricted"
+	case Symmetric:
+		return "symmetric"
+	}
+	return "unknown"
+}
+
+// ErrClosed is returned when the network was already closed.
+var ErrClosed = errors.New("simulated network is closed")
+
+// stunPort stands for the address a STUN server would observe the mapping
+// from. Symmetric NATs tie the exposed mapping to it.
+const stunPort = 0
+
+// Network connects simulated NATs with each other.
+type Network struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	closed   bool
+	external map[int]*mapping
+	internal map[int]*NAT
+}
+
+// NewNetwork creates an empty simulated network.
+func NewNetwork() *Network {
+	return &Network{
+		external: make(map[int]*mapping),
+		internal: make(map[int]*NAT),
+	}
+}
+
+// NAT is a simulated NAT device.
+type NAT struct {
+	network  *Network
+	typ      Type
+	mappings map[mappingKey]*mapping
+}
+
+type mappingKey struct {
+	local, dest int
+}
+
+type mapping struct {
+	nat        *NAT
+	local      int
+	conn       *net.UDPConn
+	sentToNATs map[*NAT]bool
+	sentTo     map[int]bool
+}
+
+// NewNAT adds a NAT of the given type to the network.
+func (n *Network) NewNAT(typ Type) (*NAT, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, ErrClosed
+	}
+
+	nat := &NAT{
+		network:  n,
+		typ:      typ,
+		mappings: make(map[mappingKey]*mapping),
+	}
+	return nat, nil
+}
+
+// Type returns the NAT type.
+func (nat *NAT) Type() Type {
+	return nat.typ
+}
+
+// Expose puts local ports behind the NAT and returns external ports they are
+// mapped to, as a STUN server would report them. For a symmetric NAT these
+// mappings are only good for talking to the STUN server, peers sending to
+// them are filtered out.
+func (nat *NAT) Expose(localPorts ...int) ([]int, error) {
+	n := nat.network
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, ErrClosed
+	}
+
+	external := make([]int, 0, len(localPorts))
+	for _, local := range localPorts {
+		if owner, ok := n.internal[local]; ok && owner != nat {
+			return nil, errors.New("local port is already behind another NAT")
+		}
+		n.internal[local] = nat
+
+		m, err := nat.mappingLocked(local, stunPort)
+		if err != nil {
+			return nil, err
+		}
+		external = append(external, m.port())
+	}
+	return external, nil
+}
+
+// mappingLocked returns the mapping local port uses to reach dest, creating
+// it if needed. Must be called with network.mu held.
+func (nat *NAT) mappingLocked(local, dest int) (*mapping, error) {
+	key := mappingKey{local: local}
+	if nat.typ == Symmetric {
+		key.dest = dest
+	}
+	if m, ok := nat.mappings[key]; ok {
+		return m, nil
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return nil, err
+	}
+	m := &mapping{
+		nat:        nat,
+		local:      local,
+		conn:       conn,
+		sentToNATs: make(map[*NAT]bool),
+		sentTo:     make(map[int]bool),
+	}
+	if dest != stunPort {
+		m.recordSent(dest)
+	}
+	nat.mappings[key] = m
+	nat.network.external[m.port()] = m
+
+	nat.network.wg.Add(1)
+	go nat.network.relay(m)
+	return m, nil
+}
+
+func (m *mapping) port() int {
+	return m.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// recordSent notes that the mapping was used to send to dest.
+// Must be called with network.mu held.
+func (m *mapping) recordSent(dest int) {
+	m.sentTo[dest] = true
+	if peer, ok := m.nat.network.external[dest]; ok {
+		m.sentToNATs[peer.nat] = true
+	}
+}
+
+// accepts reports whether the NAT lets traffic from the source mapping
+// through this mapping. Must be called with network.mu held.
+func (m *mapping) accepts(from *mapping) bool {
+	switch m.nat.typ {
+	case FullCone:
+		return true
+	case Restricted:
+		return m.sentToNATs[from.nat]
+	default:
+		return m.sentTo[from.port()]
+	}
+}
+
+// relay reads packets arriving at the external port of m. Packets come
+// straight from a local port behind another NAT, so they are first translated
+// by that NAT and then filtered by the NAT owning m before being delivered
+// to its local port from the translated address.
+func (n *Network) relay(m *mapping) {
+	defer n.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		size, from, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		source, ok := n.translate(from.Port, m)
+		if !ok {
+			continue
+		}
+		source.conn.WriteToUDP(buf[:size], &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: m.local})
+	}
+}
+
+// translate maps a local port sending to m onto the external mapping its NAT
+// uses for it and reports whether the NAT owning m lets the packet in.
+func (n *Network) translate(local int, m *mapping) (*mapping, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return nil, false
+	}
+
+	sender, ok := n.internal[local]
+	if !ok {
+		return nil, false
+	}
+
+	source, err := sender.mappingLocked(local, m.port())
+	if err != nil {
+		return nil, false
+	}
+	source.recordSent(m.port())
+
+	return source, m.accepts(source)
+}
+
+// Close closes all external sockets of the network and waits for relays
+// to stop.
+func (n *Network) Close() error {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return nil
+	}
+	n.closed = true
+	for _, m := range n.external {
+		m.conn.Close()
+	}
+	n.mu.Unlock()
+
+	n.wg.Wait()
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2026 The "MysteriumNetwork/node" Authors.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package traversaltest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNAT_Filtering(t *testing.T) {
+	for _, tc := range []struct {
+		typ                   Type
+		keepsPort             bool
+		fromSameHostOtherPort bool
+		fromOtherHost         bool
+		fromPeerToExposedPort bool
+	}{
+		{typ: FullCone, keepsPort: true, fromPeerToExposedPort: true, fromSameHostOtherPort: true, fromOtherHost: true},
+		{typ: Restricted, keepsPort: true, fromPeerToExposedPort: true, fromSameHostOtherPort: true},
+		{typ: PortRestricted, keepsPort: true, fromPeerToExposedPort: true},
+		{typ: Symmetric},
+	} {
+		t.Run(tc.typ.String(), func(t *testing.T) {
+			network := NewNetwork()
+			defer network.Close()
+
+			a, extA := behind(t, network, tc.typ, 1)
+			b, extB := behind(t, network, FullCone, 2)
+			c, _ := behind(t, network, FullCone, 1)
+
+			send(t, a[0], extB[0])
+			seen, ok := receive(t, b[0])
+			require.True(t, ok)
+			assert.Equal(t, tc.keepsPort, seen == extA[0])
+
+			send(t, b[0], seen)
+			_, ok = receive(t, a[0])
+			assert.True(t, ok, "reply to observed address")
+
+			send(t, b[0], extA[0])
+			_, ok = receive(t, a[0])
+			assert.Equal(t, tc.fromPeerToExposedPort, ok, "peer to exposed port")
+
+			send(t, b[1], extA[0])
+			_, ok = receive(t, a[0])
+			assert.Equal(t, tc.fromSameHostOtherPort, ok, "same host, other port")
+
+			send(t, c[0], extA[0])
+			_, ok = receive(t, a[0])
+			assert.Equal(t, tc.fromOtherHost, ok, "other host")
+		})
+	}
+}
+
+func TestNetwork_Close(t *testing.T) {
+	network := NewNetwork()
+	nat, err := network.NewNAT(FullCone)
+	require.NoError(t, err)
+	_, err = nat.Expose(freePort(t))
+	require.NoError(t, err)
+
+	assert.NoError(t, network.Close())
+	_, err = network.NewNAT(FullCone)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = nat.Expose(freePort(t))
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func behind(t *testing.T, network *Network, typ Type, n int) (conns []*net.UDPConn, external []int) {
+	nat, err := network.NewNAT(typ)
+	require.NoError(t, err)
+
+	var local []int
+	for i := 0; i < n; i++ {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conns = append(conns, conn)
+		local = append(local, conn.LocalAddr().(*net.UDPAddr).Port)
+	}
+
+	external, err = nat.Expose(local...)
+	require.NoError(t, err)
+	return conns, external
+}
+
+func send(t *testing.T, conn *net.UDPConn, port int) {
+	_, err := conn.WriteToUDP([]byte("ping"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	require.NoError(t, err)
+}
+
+func receive(t *testing.T, conn *net.UDPConn) (from int, ok bool) {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+	buf := make([]byte, 16)
+	_, addr, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		return 0, false
+	}
+	return addr.Port, true
+}
+
+func freePort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
@@ -136,7 +136,7 @@ func (m *dialer) Dial(ctx context.Context, consumerID, providerID identity.Ident
 	if len(config.peerPorts) == requiredConnCount {
 		dial = m.dialDirect
 	}
-	conn1, conn2, err := dial(ctx, providerID, config)
+	conn1, conn2, err := dial(traversal.WithServiceType(ctx, serviceType), providerID, config)
 	if err != nil {
 		return nil, fmt.Errorf("could not dial p2p channel: %w", err)
 	}
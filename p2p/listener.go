@@ -162,7 +162,7 @@ func (m *listener) Listen(providerID identity.Identity, serviceType string, chan
 			traceDial := config.tracer.StartStage("Provider P2P dial (preparation)")
 			log.Debug().Msgf("Pinging consumer using ports %v:%v initial ttl: %v", config.localPorts, config.peerPorts, 1)
 
-			conns, err := config.start(context.Background(), config.peerIP(), config.peerPorts, config.localPorts)
+			conns, err := config.start(traversal.WithServiceType(context.Background(), serviceType), config.peerIP(), config.peerPorts, config.localPorts)
 			if err != nil {
 				log.Err(err).Msg("Could not ping peer")
 				return
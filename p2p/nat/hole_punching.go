@@ -50,7 +50,7 @@ func NewNATHolePunchingPortProvider() PortProvider {
 	}
 
 	return &natHolePunchingPort{
-		pool:   port.NewFixedRangePool(udpPortRange),
+		pool:   port.NewFixedRangePoolWithLinger(udpPortRange, config.GetDuration(config.FlagUDPPortsLinger)),
 		pinger: traversal.NewPinger(traversal.DefaultPingConfig(), eventbus.New()),
 	}
 }
@@ -65,9 +65,11 @@ func (hp *natHolePunchingPort) PreparePorts() (ports []int, release func(), star
 		ports = append(ports, p.Num())
 	}
 
-	return ports, func() {}, hp.Start, nil
+	return ports, func() { hp.pool.Release(poolPorts...) }, hp.Start, nil
 }
 
 func (hp *natHolePunchingPort) Start(ctx context.Context, peerIP string, peerPorts, localPorts []int) ([]*net.UDPConn, error) {
-	return hp.pinger.PingConsumerPeer(context.Background(), "remove this id", peerIP, localPorts, peerPorts, providerInitialTTL, requiredConnCount)
+	// Pinging serves the service, so it must not stop with the caller, only
+	// the labels of the caller context are kept.
+	return hp.pinger.PingConsumerPeer(traversal.Detach(ctx), "remove this id", peerIP, localPorts, peerPorts, providerInitialTTL, requiredConnCount)
 }
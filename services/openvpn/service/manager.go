@@ -29,7 +29,6 @@ import (
 	"github.com/mysteriumnetwork/go-openvpn/openvpn/middlewares/server/filter"
 	"github.com/mysteriumnetwork/go-openvpn/openvpn/middlewares/state"
 	"github.com/mysteriumnetwork/go-openvpn/openvpn/tls"
-	"github.com/mysteriumnetwork/node/config"
 	"github.com/mysteriumnetwork/node/core/ip"
 	"github.com/mysteriumnetwork/node/core/node"
 	"github.com/mysteriumnetwork/node/core/port"
@@ -44,7 +43,6 @@ import (
 	openvpn_service "github.com/mysteriumnetwork/node/services/openvpn"
 	"github.com/mysteriumnetwork/node/session"
 	"github.com/mysteriumnetwork/node/utils/netutil"
-	"github.com/mysteriumnetwork/node/utils/stringutil"
 )
 
 // ProposalFactory prepares service proposal during runtime
@@ -224,7 +222,12 @@ func (m *Manager) startServer() error {
 		m.serviceOptions.Protocol,
 	)
 
-	openvpnFilterDeny := stringutil.Split(config.GetString(config.FlagFirewallProtectedNetworks), ',')
+	var openvpnFilterDeny []string
+	for _, ipNet := range nat.ProtectedNetworks() {
+		if ipNet.IP.To4() != nil {
+			openvpnFilterDeny = append(openvpnFilterDeny, ipNet.String())
+		}
+	}
 	var openvpnFilterAllow []string
 	if m.dnsOK {
 		openvpnFilterAllow = []string{m.dnsIP.String()}
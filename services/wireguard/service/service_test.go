@@ -176,3 +176,6 @@ func (service *serviceFake) Setup(nat.Options) (rules []interface{}, err error)
 func (service *serviceFake) Del([]interface{}) error { return nil }
 func (service *serviceFake) Enable() error           { return nil }
 func (service *serviceFake) Disable() error          { return nil }
+func (service *serviceFake) Diagnostics() nat.NATDiagnostics {
+	return nat.NATDiagnostics{}
+}
@@ -18,9 +18,11 @@
 package pingpong
 
 import (
+	stdjson "encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"sync"
 
 	"github.com/asdine/storm/v3/codec/json"
@@ -31,7 +33,10 @@ import (
 	"go.etcd.io/bbolt"
 )
 
-const hermesPromiseBucketName = "hermes_promises"
+const (
+	hermesPromiseBucketName        = "hermes_promises"
+	settledHermesPromiseBucketName = "hermes_promises_settled"
+)
 
 // ErrAttemptToOverwrite occurs when a promise with lower value is attempted to be overwritten on top of an existing promise.
 var ErrAttemptToOverwrite = errors.New("attempted to overwrite a promise with and equal or lower value")
@@ -136,7 +141,10 @@ func (aps *HermesPromiseStorage) getBucketName(chainID int64) string {
 func (aps *HermesPromiseStorage) List(filter HermesPromiseFilter) ([]HermesPromise, error) {
 	aps.lock.Lock()
 	defer aps.lock.Unlock()
+	return aps.list(filter)
+}
 
+func (aps *HermesPromiseStorage) list(filter HermesPromiseFilter) ([]HermesPromise, error) {
 	result := make([]HermesPromise, 0)
 	aps.bolt.RLock()
 	defer aps.bolt.RUnlock()
@@ -177,3 +185,104 @@ func (aps *HermesPromiseStorage) List(filter HermesPromiseFilter) ([]HermesPromi
 
 	return result, nil
 }
+
+// ExportedHermesPromise is the serialized form of a stored hermes promise
+// handed to external settlement tooling.
+type ExportedHermesPromise struct {
+	ChainID     int64          `json:"chain_id"`
+	ChannelID   string         `json:"channel_id"`
+	Identity    string         `json:"identity"`
+	HermesID    string         `json:"hermes_id"`
+	Promise     crypto.Promise `json:"promise"`
+	R           string         `json:"r"`
+	AgreementID *big.Int       `json:"agreement_id"`
+}
+
+type settledHermesPromise struct {
+	Amount *big.Int
+}
+
+// ExportUnsettled returns JSON encoded promises matching the filter which
+// were not marked settled yet, ordered by channel ID. Leave filter.HermesID
+// empty to export promises of all hermeses on the chain.
+func (aps *HermesPromiseStorage) ExportUnsettled(filter HermesPromiseFilter) ([]byte, error) {
+	aps.lock.Lock()
+	defer aps.lock.Unlock()
+
+	promises, err := aps.list(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]ExportedHermesPromise, 0, len(promises))
+	for _, p := range promises {
+		settled, err := aps.settled(p)
+		if err != nil {
+			return nil, err
+		}
+		if settled {
+			continue
+		}
+
+		exported = append(exported, ExportedHermesPromise{
+			ChainID:     p.Promise.ChainID,
+			ChannelID:   p.ChannelID,
+			Identity:    p.Identity.Address,
+			HermesID:    p.HermesID.Hex(),
+			Promise:     p.Promise,
+			R:           p.R,
+			AgreementID: p.AgreementID,
+		})
+	}
+	sort.Slice(exported, func(i, j int) bool {
+		return exported[i].ChannelID < exported[j].ChannelID
+	})
+
+	return stdjson.Marshal(exported)
+}
+
+// MarkSettled marks the promise of the channel settled up to the given amount,
+// so it is not exported again until a promise for a bigger amount is stored.
+//
+// The mark only affects ExportUnsettled. Get still returns the promise, and the
+// built-in settler does not settle it twice as it subtracts the amount already
+// settled on chain, which includes settlements made with exported promises.
+func (aps *HermesPromiseStorage) MarkSettled(chainID int64, channelID string, amount *big.Int) error {
+	if amount == nil {
+		return errors.New("settled amount is required")
+	}
+
+	aps.lock.Lock()
+	defer aps.lock.Unlock()
+
+	if _, err := aps.get(chainID, channelID); err != nil {
+		return err
+	}
+
+	err := aps.bolt.SetValue(aps.getSettledBucketName(chainID), channelID, settledHermesPromise{Amount: amount})
+	if err != nil {
+		return fmt.Errorf("could not mark hermes promise settled: %w", err)
+	}
+	return nil
+}
+
+func (aps *HermesPromiseStorage) settled(promise HermesPromise) (bool, error) {
+	var result settledHermesPromise
+	aps.bolt.RLock()
+	defer aps.bolt.RUnlock()
+	err := aps.bolt.DB().Get(aps.getSettledBucketName(promise.Promise.ChainID), promise.ChannelID, &result)
+	if err != nil {
+		if err.Error() == errBoltNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not get settled hermes promise: %w", err)
+	}
+	if result.Amount == nil || promise.Promise.Amount == nil {
+		return false, nil
+	}
+	return promise.Promise.Amount.Cmp(result.Amount) <= 0, nil
+}
+
+func (aps *HermesPromiseStorage) getSettledBucketName(chainID int64) string {
+	return fmt.Sprintf("%v_%v", settledHermesPromiseBucketName, chainID)
+}
@@ -18,6 +18,7 @@
 package pingpong
 
 import (
+	"encoding/json"
 	"math/big"
 	"os"
 	"testing"
@@ -144,3 +145,84 @@ func TestHermesPromiseStorageDelete(t *testing.T) {
 	_, err = hermesStorage.Get(1, firstPromise.ChannelID)
 	assert.Error(t, err)
 }
+
+func TestHermesPromiseStorageExportUnsettled(t *testing.T) {
+	dir, err := os.MkdirTemp("", "hermesPromiseStorageTest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	bolt, err := boltdb.NewStorage(dir)
+	assert.NoError(t, err)
+	defer bolt.Close()
+
+	hermesStorage := NewHermesPromiseStorage(bolt)
+
+	id := identity.FromAddress("0x44440954558C5bFA0D4153B0002B1d1E3E3f5Ff5")
+	firstHermes := common.HexToAddress("0x000000acc1")
+	secondHermes := common.HexToAddress("0x000000acc2")
+
+	firstPromise := HermesPromise{
+		ChannelID:   "1",
+		Identity:    id,
+		HermesID:    firstHermes,
+		Promise:     crypto.Promise{Amount: big.NewInt(1), Fee: big.NewInt(1), ChainID: 1},
+		R:           "some r",
+		AgreementID: big.NewInt(123),
+	}
+	secondPromise := HermesPromise{
+		ChannelID:   "2",
+		Identity:    id,
+		HermesID:    secondHermes,
+		Promise:     crypto.Promise{Amount: big.NewInt(2), Fee: big.NewInt(2), ChainID: 1},
+		R:           "some other r",
+		AgreementID: big.NewInt(1234),
+	}
+	assert.NoError(t, hermesStorage.Store(secondPromise))
+	assert.NoError(t, hermesStorage.Store(firstPromise))
+
+	exportedChannels := func(filter HermesPromiseFilter) []string {
+		data, err := hermesStorage.ExportUnsettled(filter)
+		assert.NoError(t, err)
+
+		var exported []ExportedHermesPromise
+		assert.NoError(t, json.Unmarshal(data, &exported))
+
+		channels := make([]string, 0)
+		for _, p := range exported {
+			channels = append(channels, p.ChannelID)
+		}
+		return channels
+	}
+
+	// export all hermeses in a stable order
+	assert.Equal(t, []string{"1", "2"}, exportedChannels(HermesPromiseFilter{ChainID: 1}))
+	assert.Equal(t, []string{"2"}, exportedChannels(HermesPromiseFilter{ChainID: 1, HermesID: &secondHermes}))
+
+	data, err := hermesStorage.ExportUnsettled(HermesPromiseFilter{ChainID: 1, HermesID: &firstHermes})
+	assert.NoError(t, err)
+	var exported []ExportedHermesPromise
+	assert.NoError(t, json.Unmarshal(data, &exported))
+	assert.Len(t, exported, 1)
+	assert.Equal(t, int64(1), exported[0].ChainID)
+	assert.Equal(t, id.Address, exported[0].Identity)
+	assert.Equal(t, firstHermes.Hex(), exported[0].HermesID)
+	assert.Equal(t, "some r", exported[0].R)
+
+	// settled promises are not exported again
+	assert.NoError(t, hermesStorage.MarkSettled(1, firstPromise.ChannelID, firstPromise.Promise.Amount))
+	assert.Equal(t, []string{"2"}, exportedChannels(HermesPromiseFilter{ChainID: 1}))
+
+	// but are still available to the built-in settler
+	stored, err := hermesStorage.Get(1, firstPromise.ChannelID)
+	assert.NoError(t, err)
+	assert.Equal(t, firstPromise.Promise.Amount, stored.Promise.Amount)
+
+	// until a bigger promise is stored for the channel
+	biggerPromise := firstPromise
+	biggerPromise.Promise.Amount = big.NewInt(5)
+	assert.NoError(t, hermesStorage.Store(biggerPromise))
+	assert.Equal(t, []string{"1", "2"}, exportedChannels(HermesPromiseFilter{ChainID: 1}))
+
+	assert.Equal(t, ErrNotFound, hermesStorage.MarkSettled(1, "unknown_id", big.NewInt(1)))
+	assert.Error(t, hermesStorage.MarkSettled(1, firstPromise.ChannelID, nil))
+}